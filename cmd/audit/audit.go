@@ -0,0 +1,206 @@
+package auditcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Elementary1092/pm/internal/adapter/pmssh"
+	"github.com/Elementary1092/pm/internal/advisory"
+	"github.com/Elementary1092/pm/internal/installed"
+	"github.com/Elementary1092/pm/internal/packet/parser"
+	"github.com/Elementary1092/pm/internal/packet/resolver"
+	"github.com/Elementary1092/pm/internal/repository"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	ErrUnsupportedFormat  = errors.New("unsupported output format")
+	ErrVulnerablePackages = errors.New("some packages are affected by advisories")
+)
+
+// Options change the way packages are audited
+type Options struct {
+	// Advisories is a path to the advisory database
+	Advisories string
+	// Threshold is the lowest severity of a matched advisory which fails the audit
+	Threshold advisory.Severity
+	// Installed audits versions installed by update command instead of resolving them on the server
+	Installed bool
+	// Groups of dependencies to resolve. Only runtime dependencies are audited if Groups is nil.
+	Groups resolver.Groups
+	Format string
+}
+
+// Finding is an advisory matched by a package
+type Finding struct {
+	Name     string            `json:"name"`
+	Version  string            `json:"version"`
+	Advisory advisory.Advisory `json:"advisory"`
+}
+
+type auditCommand struct {
+	data io.Reader
+	name string
+	opts Options
+	out  io.Writer
+}
+
+// NewAuditCommand checks packages listed in a package description file against the advisory database.
+// name is the same directory name which is used by update command.
+func NewAuditCommand(data io.Reader, name string, opts Options) *auditCommand {
+	if data == nil {
+		return nil
+	}
+
+	if opts.Groups == nil {
+		opts.Groups = resolver.DefaultGroups()
+	}
+
+	return &auditCommand{
+		data: data,
+		name: name,
+		opts: opts,
+		out:  os.Stdout,
+	}
+}
+
+func (ad *auditCommand) Execute(ctx context.Context) error {
+	if ad.opts.Format != FormatText && ad.opts.Format != FormatJSON {
+		return ErrUnsupportedFormat
+	}
+
+	db, err := advisory.Load(ad.opts.Advisories)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, ad.opts.Advisories)
+	}
+
+	var versions map[string]string
+	if ad.opts.Installed {
+		versions, err = ad.installedVersions()
+	} else {
+		versions, err = ad.resolvedVersions(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	findings := audit(db, versions)
+	if ad.opts.Format == FormatJSON {
+		err = writeJSON(ad.out, findings)
+	} else {
+		err = writeText(ad.out, findings)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, f := range findings {
+		if f.Advisory.Severity >= ad.opts.Threshold {
+			return ErrVulnerablePackages
+		}
+	}
+
+	return nil
+}
+
+// installedVersions reads versions recorded by update command
+func (ad *auditCommand) installedVersions() (map[string]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		wd = "."
+	}
+
+	records, err := installed.LoadAll(filepath.Join(wd, ad.name))
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]string, len(records))
+	for _, record := range records {
+		versions[record.Name] = record.Version
+	}
+
+	return versions, nil
+}
+
+// resolvedVersions resolves the whole dependency graph of a package description file
+func (ad *auditCommand) resolvedVersions(ctx context.Context) (map[string]string, error) {
+	description, err := parser.ParsePackage(ad.data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pmssh.Connect(ctx); err != nil {
+		return nil, err
+	}
+	defer pmssh.Close(ctx)
+
+	graph, err := resolver.Resolve(ctx, repository.NewRemote(), description.Packages, ad.opts.Groups)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]string, len(graph.Nodes))
+	for _, node := range graph.Nodes {
+		versions[node.Name] = node.Version
+	}
+
+	return versions, nil
+}
+
+// audit returns findings ordered by package name and descending severity
+func audit(db *advisory.Database, versions map[string]string) []Finding {
+	findings := make([]Finding, 0)
+	for name, ver := range versions {
+		for _, adv := range db.Match(name, ver) {
+			findings = append(findings, Finding{
+				Name:     name,
+				Version:  ver,
+				Advisory: adv,
+			})
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Name != findings[j].Name {
+			return findings[i].Name < findings[j].Name
+		}
+
+		return findings[i].Advisory.Severity > findings[j].Advisory.Severity
+	})
+
+	return findings
+}
+
+func writeJSON(w io.Writer, findings []Finding) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(findings)
+}
+
+func writeText(w io.Writer, findings []Finding) error {
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "No known advisories affect the packages.")
+		return err
+	}
+
+	for _, f := range findings {
+		_, err := fmt.Fprintf(w, "%s@%s: %s (%s) %s\n",
+			f.Name, f.Version, f.Advisory.ID, f.Advisory.Severity, f.Advisory.Description)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,35 +1,70 @@
 package createcmd
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"crypto/ed25519"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/Elementary1092/pm/internal/adapter/pmssh"
 	"github.com/Elementary1092/pm/internal/directory"
 	"github.com/Elementary1092/pm/internal/packet/archiver"
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+	"github.com/Elementary1092/pm/internal/packet/delta"
 	"github.com/Elementary1092/pm/internal/packet/files"
+	"github.com/Elementary1092/pm/internal/packet/manifest"
+	"github.com/Elementary1092/pm/internal/packet/metadata"
 	"github.com/Elementary1092/pm/internal/packet/parser"
+	"github.com/Elementary1092/pm/internal/packet/resolver"
+	"github.com/Elementary1092/pm/internal/packet/signature"
+	"github.com/Elementary1092/pm/internal/repository"
+	"github.com/Elementary1092/pm/internal/version"
 )
 
 var (
-	ErrInternalError = errors.New("internal command error")
+	ErrInternalError            = errors.New("internal command error")
+	ErrUnresolvableDependencies = errors.New("declared dependencies cannot be resolved")
+	ErrVersionAlreadyPublished  = errors.New("version is already published, use -force to overwrite it")
+	ErrSigningKeyNotFound       = errors.New("configured signing key is not found")
 )
 
+// partialSuffix is appended to archives which are being uploaded
+const partialSuffix = ".partial"
+
+// Options change the way a package is published
+type Options struct {
+	// AllowMissingDeps publishes a package even if some of its dependencies cannot be resolved
+	AllowMissingDeps bool
+	// SigningKey is a path to Ed25519 private key. Package is published unsigned if the file does not exist.
+	SigningKey string
+	// RequireSigningKey fails publishing if SigningKey does not exist: it is configured explicitly, not the default one
+	RequireSigningKey bool
+	// TrustedKeys is a path to the file with public keys of trusted publishers.
+	// Signatures of packed packages are verified against them before publishing.
+	TrustedKeys string
+	// Force overwrites already published version. Every overwrite is recorded in the audit log.
+	Force bool
+}
+
 type createCommand struct {
 	data io.Reader
+	opts Options
 }
 
-func NewCreateCommand(data io.Reader) *createCommand {
+func NewCreateCommand(data io.Reader, opts Options) *createCommand {
 	if data == nil {
 		return nil
 	}
 
 	return &createCommand{
 		data: data,
+		opts: opts,
 	}
 }
 
@@ -40,65 +75,555 @@ func (cr *createCommand) Execute(ctx context.Context) error {
 		return err
 	}
 
+	signingKey, err := loadSigningKey(cr.opts.SigningKey, cr.opts.RequireSigningKey)
+	if err != nil {
+		return err
+	}
+
+	if err := pmssh.Connect(ctx); err != nil {
+		return err
+	}
+	defer pmssh.Close(ctx)
+
+	repo := repository.NewRemote()
+	overwrite, err := checkVersion(ctx, repo, description.Name, description.Version, cr.opts.Force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Checking dependencies.")
+	if err := checkDependencies(ctx, description.Packets, cr.opts.AllowMissingDeps); err != nil {
+		return err
+	}
+
     fmt.Println("Collecting local files.")
-	base, filenames, err := files.CollectLocalFileNames(description.Targets)
+	pub, err := collectPublication(description)
 	if err != nil {
 		return err
 	}
 
-    tempPath := directory.MakeTempDirectoryPath()
-	defer directory.RemoveDirectory(tempPath)
+	streaming := pmssh.SupportsStreaming(ctx)
+	var staged *stagedArchive
+	if streaming {
+		fmt.Println("Creating and uploading archive.")
+		staged, err = streamArchive(ctx, pub, pub.remoteArchiveName(), pub.filenames)
+	} else {
+		fmt.Println("Creating archive in a temporary directory, server cannot replace files atomically.")
+		staged, err = archiveToTempFile(pub, pub.description.Name, pub.filenames)
+	}
+	if err != nil {
+		return err
+	}
+	defer staged.cleanup(ctx)
 
-    fmt.Println("Creating archive.")
-	archiveName := directory.MakeArchivePathName(tempPath, description.Name, description.Version)
-	archiveName, err = archiver.Archive(base, archiveName, filenames)
+	pub.meta.SetDigest(staged.digest)
+	manifestData, err := pub.setManifest(staged.manifest)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(archiveName)
 
-	metaFilePath := directory.MakeMetadataPathName(tempPath, description.Name, description.Version)
-	if err := makeMetadataFile(metaFilePath, description.Packets); err != nil {
-		return ErrInternalError
+	stagedDelta, err := stageDelta(ctx, repo, pub, staged.manifest, streaming)
+	if err != nil {
+		return err
+	}
+	if stagedDelta != nil {
+		defer stagedDelta.cleanup(ctx)
+		pub.meta.Delta = &metadata.Delta{
+			From:     stagedDelta.from,
+			Checksum: stagedDelta.digest.Sum,
+			Size:     stagedDelta.digest.Size,
+		}
 	}
-	defer os.Remove(metaFilePath)
 
-	if err := pmssh.Connect(ctx); err != nil {
+	metaData, err := encodeMetadata(pub.meta)
+	if err != nil {
 		return err
 	}
-	defer pmssh.Close(ctx)
+
+	var sigData []byte
+	if signingKey != nil {
+		fmt.Println("Signing package.")
+		sigData, err = signPackage(signingKey, description.Name, description.Version, staged.digest, metaData)
+		if err != nil {
+			return err
+		}
+	}
+
+	rel := &release{
+		name:         description.Name,
+		version:      description.Version,
+		format:       pub.meta.ArchiveFormat(),
+		archive:      staged,
+		delta:        stagedDelta,
+		manifestData: manifestData,
+		metaData:     metaData,
+		sigData:      sigData,
+	}
+
+	return rel.upload(ctx, repo, overwrite)
+}
+
+// checkVersion refuses to overwrite a published version unless force is set.
+// true is returned if the version is going to be overwritten.
+func checkVersion(ctx context.Context, repo *repository.Remote, name string, ver string, force bool) (bool, error) {
+	overwrite := repo.Exists(ctx, name, ver)
+	if overwrite && !force {
+		return false, fmt.Errorf("%w: %s@%s", ErrVersionAlreadyPublished, name, ver)
+	}
+
+	return overwrite, nil
+}
+
+// release is a package with every file which is uploaded to the server
+type release struct {
+	name    string
+	version string
+	format  archiver.Format
+	archive *stagedArchive
+	// delta is nil if the package is published without a delta
+	delta        *stagedDelta
+	manifestData []byte
+	metaData     []byte
+	// sigData is nil if the package is unsigned
+	sigData []byte
+}
+
+func (rl *release) archiveName() string {
+	return rl.name + rl.format.Extension()
+}
+
+// upload makes the release visible on the server.
+// Metadata and latest links are uploaded after the archive and its signature,
+// so consumers never see a version without its archive or a signed package without its signature.
+func (rl *release) upload(ctx context.Context, repo *repository.Remote, overwrite bool) error {
+	remoteSignature := directory.MakeRemoteSignatureName(rl.name, rl.version, rl.archiveName())
+	// signature of the overwritten archive would fail verification of the new one
+	removeSignature := overwrite && rl.sigData == nil && pmssh.DoesFileExist(ctx, remoteSignature)
+	if overwrite {
+		fmt.Printf("Warning: overwriting published version %s@%s\n", rl.name, rl.version)
+		if err := recordOverwrite(ctx, repo, rl.name, rl.version, rl.archive.digest, removeSignature); err != nil {
+			return err
+		}
+	}
+
+	if removeSignature {
+		fmt.Println("Warning: removing signature of the overwritten version, package is published unsigned")
+		if err := pmssh.Remove(ctx, remoteSignature); err != nil {
+			return err
+		}
+	}
 
     fmt.Println("Uploading files...")
-	remoteArchive := directory.MakeRemoteArchiveName(description.Name, description.Version, description.Name)
-	if err := pmssh.Upload(ctx, remoteArchive, archiveName); err != nil {
+	remoteArchive := directory.MakeRemoteArchiveName(rl.name, rl.version, rl.archiveName())
+	if err := rl.archive.place(ctx, remoteArchive); err != nil {
+		return err
+	}
+
+	if rl.sigData != nil {
+		if err := uploadData(ctx, remoteSignature, rl.sigData); err != nil {
+			return err
+		}
+	}
+
+	if rl.delta != nil {
+		remoteDelta := directory.MakeRemoteDeltaName(rl.name, rl.version, rl.delta.from, rl.format.Extension())
+		if err := rl.delta.place(ctx, remoteDelta); err != nil {
+			return err
+		}
+	}
+
+	remoteManifest := directory.MakeRemoteManifestName(rl.name, rl.version)
+	if err := uploadData(ctx, remoteManifest, rl.manifestData); err != nil {
 		return err
 	}
 
-	linkName := directory.MakeLatestArchiveLink(description.Name)
-	if err := pmssh.CreateSymbolicLink(ctx, linkName, archiveName); err != nil {
+	linkName := directory.MakeLatestArchiveLink(rl.name)
+	if err := pmssh.CreateSymbolicLink(ctx, linkName, remoteArchive); err != nil {
 		return err
 	}
 
-	remoteMetadata := directory.MakeRemoteMetadataName(description.Name, description.Version)
-	if err := pmssh.Upload(ctx, remoteMetadata, metaFilePath); err != nil {
+	remoteMetadata := directory.MakeRemoteMetadataName(rl.name, rl.version)
+	if err := uploadData(ctx, remoteMetadata, rl.metaData); err != nil {
 		return err
 	}
 
-	metaLinkName := directory.MakeLatestMetadataLink(description.Name)
-	if err := pmssh.CreateSymbolicLink(ctx, metaLinkName, metaFilePath); err != nil {
+	metaLinkName := directory.MakeLatestMetadataLink(rl.name)
+	if err := pmssh.CreateSymbolicLink(ctx, metaLinkName, remoteMetadata); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func makeMetadataFile(filePath string, data any) error {
-	f, err := os.Create(filePath)
+// collectPublication collects files of a package and chooses the way they are archived
+func collectPublication(description *parser.Packet) (*publication, error) {
+	preserve := description.PreserveOptions()
+	collection, err := files.CollectLocalFiles(description.Targets, files.CollectOptions{
+		Root:      description.Root,
+		Symlinks:  description.SymlinkPolicy(),
+		EmptyDirs: preserve.EmptyDirs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	archiveOptions, err := makeArchiveOptions(description)
+	if err != nil {
+		return nil, err
+	}
+	archiveOptions.PathsInArchive = collection.PathsInArchive
+	archiveOptions.FollowedLinks = collection.FollowedLinks
+
+	return &publication{
+		description: description,
+		meta:        metadata.New(description),
+		base:        collection.Root,
+		filenames:   collection.FileNames,
+		options:     archiveOptions,
+	}, nil
+}
+
+// publication is a package which is being published
+type publication struct {
+	description *parser.Packet
+	meta        *metadata.Metadata
+	base        string
+	filenames   []string
+	options     archiver.Options
+}
+
+func (p *publication) archiveName() string {
+	return p.description.Name + p.meta.ArchiveFormat().Extension()
+}
+
+func (p *publication) remoteArchiveName() string {
+	return directory.MakeRemoteArchiveName(p.description.Name, p.description.Version, p.archiveName())
+}
+
+// setManifest records checksum of the encoded manifest of archived files in the metadata
+func (p *publication) setManifest(packManifest *manifest.Manifest) ([]byte, error) {
+	manifestData, err := encodeManifest(packManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestDigest, err := checksum.Compute(bytes.NewReader(manifestData))
+	if err != nil {
+		return nil, ErrInternalError
+	}
+	p.meta.SetManifestDigest(manifestDigest)
+
+	return manifestData, nil
+}
+
+func (p *publication) remoteDeltaName(from string) string {
+	return directory.MakeRemoteDeltaName(p.description.Name, p.description.Version, from, p.meta.ArchiveFormat().Extension())
+}
+
+// stagedArchive is a created archive which is not yet visible on the server
+type stagedArchive struct {
+	digest checksum.Digest
+	// manifest of the archived files
+	manifest *manifest.Manifest
+	// place moves the archive to its remote location
+	place   func(ctx context.Context, remoteArchive string) error
+	cleanup func(ctx context.Context)
+}
+
+// streamArchive writes the archive of filenames directly to the server next to remoteArchive,
+// checksum is computed on the fly
+func streamArchive(ctx context.Context, pub *publication, remoteArchive string, filenames []string) (*stagedArchive, error) {
+	partialArchive := remoteArchive + partialSuffix
+	digestWriter := checksum.NewWriter()
+	var archived *manifest.Manifest
+	err := pmssh.UploadStream(ctx, partialArchive, func(w io.Writer) error {
+		var err error
+		archived, err = archiver.ArchiveTo(io.MultiWriter(w, digestWriter), pub.meta.ArchiveFormat(), pub.base, filenames, pub.options)
+		return err
+	})
+	if err != nil {
+		removePartial(ctx, partialArchive)
+		return nil, err
+	}
+
+	placed := false
+	return &stagedArchive{
+		digest:   digestWriter.Digest(),
+		manifest: archived,
+		place: func(ctx context.Context, remoteArchive string) error {
+			if err := pmssh.Rename(ctx, partialArchive, remoteArchive); err != nil {
+				return err
+			}
+			placed = true
+			return nil
+		},
+		cleanup: func(ctx context.Context) {
+			if !placed {
+				removePartial(ctx, partialArchive)
+			}
+		},
+	}, nil
+}
+
+// removePartial deletes the partial archive and directories created for it, if they are left empty.
+// Otherwise the version directory without metadata would stay on the server.
+func removePartial(ctx context.Context, partialArchive string) {
+	pmssh.Remove(ctx, partialArchive)
+	versionDir := filepath.Dir(partialArchive)
+	if err := pmssh.RemoveEmptyDirectory(ctx, versionDir); err == nil {
+		pmssh.RemoveEmptyDirectory(ctx, filepath.Dir(versionDir))
+	}
+}
+
+// archiveToTempFile creates the archive of filenames in a temporary directory under the working directory
+func archiveToTempFile(pub *publication, name string, filenames []string) (*stagedArchive, error) {
+	tempPath := directory.MakeTempDirectoryPath()
+	archiveName := directory.MakeArchivePathName(tempPath, name, pub.description.Version) + pub.meta.ArchiveFormat().Extension()
+	archiveName, err := archiver.ArchiveWithOptions(pub.base, archiveName, filenames, pub.options)
+	if err != nil {
+		directory.RemoveDirectory(tempPath)
+		return nil, err
+	}
+
+	digest, err := checksum.ComputeFile(archiveName)
+	if err != nil {
+		directory.RemoveDirectory(tempPath)
+		return nil, err
+	}
+
+	archived, err := archiver.ReadManifest(archiveName)
+	if err != nil {
+		directory.RemoveDirectory(tempPath)
+		return nil, err
+	}
+
+	return &stagedArchive{
+		digest:   digest,
+		manifest: archived,
+		place: func(ctx context.Context, remoteArchive string) error {
+			return pmssh.Upload(ctx, remoteArchive, archiveName)
+		},
+		cleanup: func(ctx context.Context) {
+			directory.RemoveDirectory(tempPath)
+		},
+	}, nil
+}
+
+// stagedDelta is a delta archive from the version from which is not yet visible on the server
+type stagedDelta struct {
+	*stagedArchive
+	from string
+}
+
+// stageDelta archives files which differ from the preceding published version.
+// nil is returned if there is no such version, it was published without a manifest
+// or every file has changed, so consumers would download the full archive anyway.
+// Failure to list published versions is returned, so a package is never silently published without a delta.
+func stageDelta(ctx context.Context, repo *repository.Remote, pub *publication, next *manifest.Manifest, streaming bool) (*stagedDelta, error) {
+	versions, err := repo.Versions(ctx, pub.description.Name)
+	if errors.Is(err, pmssh.ErrDirectoryNotFound) {
+		// the first version of the package
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list published versions of %s: %w", pub.description.Name, err)
+	}
+
+	from := version.Preceding(versions, pub.description.Version)
+	if from == "" {
+		return nil, nil
+	}
+
+	previousMeta, err := repo.Metadata(ctx, pub.description.Name, from)
 	if err != nil {
+		fmt.Printf("Warning: delta is not published, metadata of %s is unavailable\n", from)
+		return nil, nil
+	}
+
+	previous, err := repo.Manifest(ctx, pub.description.Name, from, previousMeta)
+	if err != nil {
+		fmt.Printf("Warning: delta is not published, manifest of %s is unavailable: %v\n", from, err)
+		return nil, nil
+	}
+
+	diff := delta.Compare(previous, next)
+	if !diff.Useful() {
+		fmt.Printf("Delta is not published, every file differs from %s.\n", from)
+		return nil, nil
+	}
+
+	filenames, err := deltaFileNames(pub, diff.Shipped())
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Creating delta from %s: %d added, %d changed, %d removed files.\n",
+		from, len(diff.Added), len(diff.Changed), len(diff.Removed))
+	var staged *stagedArchive
+	if streaming {
+		staged, err = streamArchive(ctx, pub, pub.remoteDeltaName(from), filenames)
+	} else {
+		staged, err = archiveToTempFile(pub, pub.description.Name+".delta-"+from, filenames)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &stagedDelta{
+		stagedArchive: staged,
+		from:          from,
+	}, nil
+}
+
+// deltaFileNames selects collected files which are shipped in the delta archive.
+// Directories are always kept, so empty directories of the new version are created.
+func deltaFileNames(pub *publication, shipped []string) ([]string, error) {
+	selected := make(map[string]bool, len(shipped))
+	for _, path := range shipped {
+		selected[path] = true
+	}
+
+	filenames := make([]string, 0, len(shipped))
+	for _, fileName := range pub.filenames {
+		info, err := os.Lstat(fileName)
+		if err == nil && pub.options.FollowedLinks[fileName] {
+			info, err = os.Stat(fileName)
+		}
+		if err != nil {
+			return nil, archiver.ErrFailedToOpenFile
+		}
+
+		rel, err := pub.options.PathInArchive(pub.base, fileName)
+		if err != nil {
+			return nil, err
+		}
+
+		if info.IsDir() || selected[filepath.ToSlash(rel)] {
+			filenames = append(filenames, fileName)
+		}
+	}
+
+	return filenames, nil
+}
+
+func uploadData(ctx context.Context, remotePath string, data []byte) error {
+	return pmssh.UploadStream(ctx, remotePath, func(w io.Writer) error {
+		_, err := w.Write(data)
 		return err
+	})
+}
+
+// makeArchiveOptions normalizes modification times to SOURCE_DATE_EPOCH if it is set.
+// Reproducible packages also get normalized permission bits and fixed modification time.
+func makeArchiveOptions(description *parser.Packet) (archiver.Options, error) {
+	preserve := description.PreserveOptions()
+	opts := archiver.Options{
+		PreserveMode:    preserve.Mode,
+		PreserveModTime: preserve.ModTime,
+		// links are collected only if they should be preserved or followed, followed ones are listed separately
+		PreserveSymlinks: true,
+		NormalizeModes:   description.Reproducible,
+		CompressionLevel: description.CompressionLevel,
+	}
+
+	epoch, ok, err := archiver.SourceDateEpoch()
+	if err != nil {
+		return opts, err
+	}
+
+	switch {
+	case ok:
+		opts.ModTime = epoch
+	case description.Reproducible:
+		opts.ModTime = archiver.NormalizedModTime
+	}
+
+	return opts, nil
+}
+
+// recordOverwrite is called before uploading, so an overwrite is never left unrecorded
+func recordOverwrite(ctx context.Context, repo *repository.Remote, name string, ver string, digest checksum.Digest, removesSignature bool) error {
+	entry := repository.NewAuditEntry(repository.AuditActionOverwrite, name, ver)
+	entry.Current = digest.Sum
+	entry.SignatureRemoved = removesSignature
+	if previous, err := repo.Metadata(ctx, name, ver); err == nil {
+		entry.Previous = previous.Checksum
 	}
 
-	encoder := json.NewEncoder(f)
-	return encoder.Encode(data)
+	return repo.RecordAudit(ctx, entry)
 }
 
+// loadSigningKey returns nil key if signing key is not configured.
+// Missing key is an error if it is required, so a mistyped path does not result in an unsigned package.
+func loadSigningKey(keyPath string, required bool) (ed25519.PrivateKey, error) {
+	if keyPath == "" {
+		return nil, nil
+	}
+
+	if _, err := os.Stat(keyPath); errors.Is(err, fs.ErrNotExist) {
+		if required {
+			return nil, fmt.Errorf("%w: %s", ErrSigningKeyNotFound, keyPath)
+		}
+
+		fmt.Fprintln(os.Stderr, "Warning: signing key is not found, package will not be signed")
+		return nil, nil
+	}
+
+	return signature.LoadPrivateKey(keyPath)
+}
+
+// checkDependencies refuses to publish a package which depends on versions absent on the server,
+// because such package would break every consumer
+func checkDependencies(ctx context.Context, deps []parser.PackageDescription, allowMissing bool) error {
+	unresolvable := resolver.CheckDependencies(ctx, repository.NewRemote(), deps)
+	if len(unresolvable) == 0 {
+		return nil
+	}
+
+	var report strings.Builder
+	for _, u := range unresolvable {
+		fmt.Fprintf(&report, "\n  %s", u)
+	}
+
+	if allowMissing {
+		fmt.Printf("Warning: %v:%s\n", ErrUnresolvableDependencies, report.String())
+		return nil
+	}
+
+	return fmt.Errorf("%w:%s", ErrUnresolvableDependencies, report.String())
+}
+
+// signPackage signs the archive digest together with the metadata file content
+func signPackage(key ed25519.PrivateKey, name string, ver string, digest checksum.Digest, meta []byte) ([]byte, error) {
+	sig := signature.Sign(key, signature.Payload{
+		Name:     name,
+		Version:  ver,
+		Archive:  digest,
+		Metadata: meta,
+	})
+
+	var encoded bytes.Buffer
+	if err := sig.Encode(&encoded); err != nil {
+		return nil, ErrInternalError
+	}
+
+	return encoded.Bytes(), nil
+}
+
+func encodeMetadata(meta *metadata.Metadata) ([]byte, error) {
+	var encoded bytes.Buffer
+	if err := meta.Encode(&encoded); err != nil {
+		return nil, ErrInternalError
+	}
+
+	return encoded.Bytes(), nil
+}
+
+func encodeManifest(packManifest *manifest.Manifest) ([]byte, error) {
+	var encoded bytes.Buffer
+	if err := packManifest.Encode(&encoded); err != nil {
+		return nil, ErrInternalError
+	}
+
+	return encoded.Bytes(), nil
+}
@@ -0,0 +1,240 @@
+package createcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Elementary1092/pm/internal/directory"
+	"github.com/Elementary1092/pm/internal/packet/archiver"
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+	"github.com/Elementary1092/pm/internal/packet/manifest"
+	"github.com/Elementary1092/pm/internal/packet/parser"
+)
+
+var (
+	ErrFailedToWritePackage = errors.New("failed to write packed package")
+)
+
+// Files of a packed package, the archive is named as on the server
+const (
+	packedDescriptionName = "release.json"
+	packedMetadataName    = "meta"
+	packedManifestName    = "manifest"
+	signatureSuffix       = ".sig"
+)
+
+// packedDescription identifies a packed package, metadata does not store name and version
+type packedDescription struct {
+	Name    string          `json:"name" validate:"min=1,pack_name"`
+	Version string          `json:"ver" validate:"min=1,pack_ver"`
+	Format  archiver.Format `json:"format"`
+}
+
+// PackOptions change the way a package is packed
+type PackOptions struct {
+	// Out is a directory the package is packed to, every package gets its own <name>-<version> subdirectory
+	Out string
+	// List prints collected files with their sizes instead of packing them
+	List bool
+	// SigningKey is a path to Ed25519 private key. Package is packed unsigned if the file does not exist.
+	SigningKey string
+	// RequireSigningKey fails packing if SigningKey does not exist
+	RequireSigningKey bool
+}
+
+type packCommand struct {
+	data io.Reader
+	opts PackOptions
+	out  io.Writer
+}
+
+// NewPackCommand builds the archive and metadata of a package like create command does,
+// but writes them to a local directory instead of uploading, so the package could be inspected
+// and published later by publish command. Dependencies are not checked and no delta is created,
+// because it requires the server.
+func NewPackCommand(data io.Reader, opts PackOptions) *packCommand {
+	if data == nil {
+		return nil
+	}
+
+	return &packCommand{
+		data: data,
+		opts: opts,
+		out:  os.Stdout,
+	}
+}
+
+func (pc *packCommand) Execute(ctx context.Context) error {
+	description, err := parser.ParsePacket(pc.data)
+	if err != nil {
+		return err
+	}
+
+	pub, err := collectPublication(description)
+	if err != nil {
+		return err
+	}
+
+	if pc.opts.List {
+		return listFiles(pc.out, pub)
+	}
+
+	signingKey, err := loadSigningKey(pc.opts.SigningKey, pc.opts.RequireSigningKey)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(pc.opts.Out, os.ModePerm); err != nil {
+		return ErrFailedToWritePackage
+	}
+
+	// the package is written next to its final location and replaces it only when it is complete
+	tempDir, err := os.MkdirTemp(pc.opts.Out, ".pack")
+	if err != nil {
+		return ErrFailedToWritePackage
+	}
+	defer os.RemoveAll(tempDir)
+
+	fmt.Fprintln(pc.out, "Creating archive.")
+	digest, packManifest, err := archiveToFile(pub, filepath.Join(tempDir, pub.archiveName()))
+	if err != nil {
+		return err
+	}
+
+	pub.meta.SetDigest(digest)
+	manifestData, err := pub.setManifest(packManifest)
+	if err != nil {
+		return err
+	}
+
+	metaData, err := encodeMetadata(pub.meta)
+	if err != nil {
+		return err
+	}
+
+	descriptionData, err := json.MarshalIndent(packedDescription{
+		Name:    description.Name,
+		Version: description.Version,
+		Format:  pub.meta.ArchiveFormat(),
+	}, "", " ")
+	if err != nil {
+		return ErrInternalError
+	}
+
+	packed := map[string][]byte{
+		packedDescriptionName: append(descriptionData, '\n'),
+		packedMetadataName:    metaData,
+		packedManifestName:    manifestData,
+	}
+
+	if signingKey != nil {
+		fmt.Fprintln(pc.out, "Signing package.")
+		sigData, err := signPackage(signingKey, description.Name, description.Version, digest, metaData)
+		if err != nil {
+			return err
+		}
+		packed[pub.archiveName()+signatureSuffix] = sigData
+	}
+
+	for name, data := range packed {
+		if err := os.WriteFile(filepath.Join(tempDir, name), data, 0644); err != nil {
+			return ErrFailedToWritePackage
+		}
+	}
+
+	// temporary directories are accessible only by the owner
+	if err := os.Chmod(tempDir, 0755); err != nil {
+		return ErrFailedToWritePackage
+	}
+
+	packedDir := directory.MakePackedDirectoryPath(pc.opts.Out, description.Name, description.Version)
+	if err := os.RemoveAll(packedDir); err != nil {
+		return ErrFailedToWritePackage
+	}
+
+	if err := os.Rename(tempDir, packedDir); err != nil {
+		return ErrFailedToWritePackage
+	}
+
+	fmt.Fprintf(pc.out, "Package is packed to %s.\n", packedDir)
+	return nil
+}
+
+// archiveToFile computes checksum of the archive while it is written
+func archiveToFile(pub *publication, archiveName string) (checksum.Digest, *manifest.Manifest, error) {
+	f, err := os.OpenFile(archiveName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return checksum.Digest{}, nil, archiver.ErrFailedToCreateArchieve
+	}
+	defer f.Close()
+
+	digestWriter := checksum.NewWriter()
+	packManifest, err := archiver.ArchiveTo(io.MultiWriter(f, digestWriter), pub.meta.ArchiveFormat(), pub.base, pub.filenames, pub.options)
+	if err != nil {
+		return checksum.Digest{}, nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		return checksum.Digest{}, nil, archiver.ErrFailedToCreateArchieve
+	}
+
+	return digestWriter.Digest(), packManifest, nil
+}
+
+// listedFile is a collected file with its path in the package
+type listedFile struct {
+	path string
+	size int64
+	dir  bool
+}
+
+// listFiles prints every collected file with its size in the order of the archive
+func listFiles(w io.Writer, pub *publication) error {
+	listed := make([]listedFile, 0, len(pub.filenames))
+	var total int64
+	for _, fileName := range pub.filenames {
+		pathInArchive, err := pub.options.PathInArchive(pub.base, fileName)
+		if err != nil {
+			return err
+		}
+
+		info, err := os.Lstat(fileName)
+		if err == nil && pub.options.FollowedLinks[fileName] {
+			info, err = os.Stat(fileName)
+		}
+		if err != nil {
+			return archiver.ErrFailedToOpenFile
+		}
+
+		file := listedFile{
+			path: filepath.ToSlash(pathInArchive),
+			dir:  info.IsDir(),
+		}
+		if !file.dir {
+			file.size = info.Size()
+			total += file.size
+		}
+		listed = append(listed, file)
+	}
+
+	sort.Slice(listed, func(i, j int) bool {
+		return listed[i].path < listed[j].path
+	})
+
+	for _, file := range listed {
+		if file.dir {
+			fmt.Fprintf(w, "%12s  %s/\n", "-", file.path)
+			continue
+		}
+		fmt.Fprintf(w, "%12d  %s\n", file.size, file.path)
+	}
+	fmt.Fprintf(w, "%d files, %d bytes\n", len(listed), total)
+
+	return nil
+}
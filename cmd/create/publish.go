@@ -0,0 +1,197 @@
+package createcmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Elementary1092/pm/internal/adapter/pmssh"
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+	"github.com/Elementary1092/pm/internal/packet/metadata"
+	"github.com/Elementary1092/pm/internal/packet/signature"
+	validate "github.com/Elementary1092/pm/internal/packet/validator"
+	"github.com/Elementary1092/pm/internal/repository"
+)
+
+var (
+	ErrInvalidPackedPackage  = errors.New("invalid packed package")
+	ErrPackedPackageModified = errors.New("packed package does not match its metadata")
+)
+
+type publishCommand struct {
+	dir  string
+	opts Options
+}
+
+// NewPublishCommand uploads a package packed by pack command without changing any of its files.
+// dir is the <name>-<version> directory created by pack command, SigningKey of opts is not used,
+// because the package is signed when it is packed.
+func NewPublishCommand(dir string, opts Options) *publishCommand {
+	if dir == "" {
+		return nil
+	}
+
+	return &publishCommand{
+		dir:  dir,
+		opts: opts,
+	}
+}
+
+func (pb *publishCommand) Execute(ctx context.Context) error {
+	fmt.Println("Checking packed package.")
+	keyring, err := loadTrustedKeys(pb.opts.TrustedKeys)
+	if err != nil {
+		return err
+	}
+
+	rel, meta, err := loadPacked(pb.dir, keyring)
+	if err != nil {
+		return err
+	}
+
+	if err := pmssh.Connect(ctx); err != nil {
+		return err
+	}
+	defer pmssh.Close(ctx)
+
+	repo := repository.NewRemote()
+	overwrite, err := checkVersion(ctx, repo, rel.name, rel.version, pb.opts.Force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Checking dependencies.")
+	if err := checkDependencies(ctx, meta.Packets, pb.opts.AllowMissingDeps); err != nil {
+		return err
+	}
+
+	return rel.upload(ctx, repo, overwrite)
+}
+
+// loadPacked reads a packed package and checks that the archive and the manifest match the metadata
+// and that the signature is made by a trusted key, consumers would reject the package otherwise
+func loadPacked(dir string, keyring *signature.Keyring) (*release, *metadata.Metadata, error) {
+	descriptionData, err := os.ReadFile(filepath.Join(dir, packedDescriptionName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s is not found in %s", ErrInvalidPackedPackage, packedDescriptionName, dir)
+	}
+
+	// name and version become parts of remote paths
+	var description packedDescription
+	if err := json.Unmarshal(descriptionData, &description); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidPackedPackage, packedDescriptionName)
+	}
+	if err := validate.Validator().Struct(description); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: invalid name or version", ErrInvalidPackedPackage, packedDescriptionName)
+	}
+
+	metaData, err := os.ReadFile(filepath.Join(dir, packedMetadataName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s is not found", ErrInvalidPackedPackage, packedMetadataName)
+	}
+
+	meta, err := metadata.Decode(bytes.NewReader(metaData))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if meta.ArchiveFormat() != description.Format {
+		return nil, nil, fmt.Errorf("%w: format differs from the metadata", ErrInvalidPackedPackage)
+	}
+
+	// delta archives are never packed, metadata should not promise one
+	if meta.Delta != nil {
+		return nil, nil, fmt.Errorf("%w: metadata refers to a delta archive", ErrInvalidPackedPackage)
+	}
+
+	rel := &release{
+		name:     description.Name,
+		version:  description.Version,
+		format:   description.Format,
+		metaData: metaData,
+	}
+
+	archivePath := filepath.Join(dir, rel.archiveName())
+	digest, ok := meta.Digest()
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: metadata has no checksum", ErrInvalidPackedPackage)
+	}
+	if err := checksum.VerifyFile(archivePath, digest); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %v", ErrPackedPackageModified, rel.archiveName(), err)
+	}
+
+	rel.manifestData, err = os.ReadFile(filepath.Join(dir, packedManifestName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s is not found", ErrInvalidPackedPackage, packedManifestName)
+	}
+
+	manifestDigest, ok := meta.ManifestDigest()
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: metadata has no manifest checksum", ErrInvalidPackedPackage)
+	}
+	actual, err := checksum.Compute(bytes.NewReader(rel.manifestData))
+	if err != nil {
+		return nil, nil, ErrInternalError
+	}
+	if err := manifestDigest.Verify(actual); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %v", ErrPackedPackageModified, packedManifestName, err)
+	}
+
+	rel.sigData, err = os.ReadFile(archivePath + signatureSuffix)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Println("Warning: packed package is not signed, it will be published unsigned")
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s%s", ErrInvalidPackedPackage, rel.archiveName(), signatureSuffix)
+	} else if err := verifyPackedSignature(rel, digest, keyring); err != nil {
+		return nil, nil, err
+	}
+
+	rel.archive = &stagedArchive{
+		digest: digest,
+		place: func(ctx context.Context, remoteArchive string) error {
+			return pmssh.Upload(ctx, remoteArchive, archivePath)
+		},
+		cleanup: func(ctx context.Context) {},
+	}
+
+	return rel, meta, nil
+}
+
+// verifyPackedSignature checks the signature of a packed package against the archive digest and the metadata
+func verifyPackedSignature(rel *release, digest checksum.Digest, keyring *signature.Keyring) error {
+	sig, err := signature.Decode(bytes.NewReader(rel.sigData))
+	if err != nil {
+		return fmt.Errorf("%w: %s%s: %v", ErrInvalidPackedPackage, rel.archiveName(), signatureSuffix, err)
+	}
+
+	payload := signature.Payload{
+		Name:     rel.name,
+		Version:  rel.version,
+		Archive:  digest,
+		Metadata: rel.metaData,
+	}
+	if err := keyring.Verify(sig, payload); err != nil {
+		return fmt.Errorf("%w: %s%s: %v", ErrPackedPackageModified, rel.archiveName(), signatureSuffix, err)
+	}
+
+	return nil
+}
+
+// loadTrustedKeys returns an empty keyring if there is no trusted keys file,
+// so that every signed package is rejected as signed by an unknown key
+func loadTrustedKeys(path string) (*signature.Keyring, error) {
+	if path == "" {
+		return signature.ParseKeyring(nil)
+	}
+
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return signature.ParseKeyring(nil)
+	}
+
+	return signature.LoadKeyring(path)
+}
@@ -0,0 +1,48 @@
+package excludedcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Elementary1092/pm/internal/packet/files"
+	"github.com/Elementary1092/pm/internal/packet/parser"
+)
+
+type excludedCommand struct {
+	data io.Reader
+	out  io.Writer
+}
+
+// NewExcludedCommand lists files of a package declaration which are excluded
+// by exclude patterns of targets or by ignore files, together with the rule excluding them
+func NewExcludedCommand(data io.Reader) *excludedCommand {
+	if data == nil {
+		return nil
+	}
+
+	return &excludedCommand{
+		data: data,
+		out:  os.Stdout,
+	}
+}
+
+func (ex *excludedCommand) Execute(ctx context.Context) error {
+	description, err := parser.ParsePacket(ex.data)
+	if err != nil {
+		return err
+	}
+
+	preserve := description.PreserveOptions()
+	_, _, err = files.CollectLocalFileNamesWithOptions(description.Targets, files.CollectOptions{
+		Root:      description.Root,
+		Symlinks:  description.SymlinkPolicy(),
+		EmptyDirs: preserve.EmptyDirs,
+		Excluded: func(path string, rule *files.Rule) {
+			fmt.Fprintf(ex.out, "%s\t%s\n", path, rule)
+		},
+	})
+
+	return err
+}
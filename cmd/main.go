@@ -10,13 +10,52 @@ import (
 	"path/filepath"
 	"strings"
 
+	auditcmd "github.com/Elementary1092/pm/cmd/audit"
 	createcmd "github.com/Elementary1092/pm/cmd/create"
+	excludedcmd "github.com/Elementary1092/pm/cmd/excluded"
+	outdatedcmd "github.com/Elementary1092/pm/cmd/outdated"
+	sbomcmd "github.com/Elementary1092/pm/cmd/sbom"
+	treecmd "github.com/Elementary1092/pm/cmd/tree"
 	updatecmd "github.com/Elementary1092/pm/cmd/update"
+	verifycmd "github.com/Elementary1092/pm/cmd/verify"
+	"github.com/Elementary1092/pm/internal/advisory"
+	"github.com/Elementary1092/pm/internal/config"
+	"github.com/Elementary1092/pm/internal/packet/archiver"
+	"github.com/Elementary1092/pm/internal/packet/resolver"
 )
 
-const helpPrompt = `pm -create <filename> - create package from package declaration files
+const helpPrompt = `pm -create <filename> [-allow-missing-deps] [-force] - create package from package declaration files.
+    Publishing fails if some of declared dependencies cannot be resolved, unless -allow-missing-deps is set.
+    Published versions are immutable, -force overwrites a version and records it in the audit log
 
-pm -update <filename> - update package from package description files`
+pm -pack <filename> [-out <directory>] [-list] - build package archive and metadata in <directory>/<name>-<version>
+    without uploading them, -list prints files of the package with their sizes instead
+
+pm -publish <directory> [-allow-missing-deps] [-force] - upload a package packed by -pack without changing it
+
+pm -excluded <filename> - list files excluded by exclude patterns of targets and by .pmignore files,
+    together with the rule excluding each of them
+
+pm -update <filename> [-with <groups>] [-without <groups>] [-allow-unsigned] - update package from package description files.
+    Only runtime dependencies are fetched by default, groups are: runtime, dev, build, optional.
+    Unsigned packages and packages signed by untrusted keys are rejected, unless -allow-unsigned is set
+
+pm -outdated <filename> [-format text|json] - list installed, wanted and latest versions of packages,
+    exits with non-zero code if any package is outdated
+
+pm -audit <filename> [-installed] [-advisories <file>] [-severity low|medium|high|critical] [-format text|json] -
+    report advisories affecting resolved (or installed, if -installed is set) packages,
+    exits with non-zero code if any advisory is at least as severe as -severity (low by default)
+
+pm -verify <filename> [-allow-missing-manifest] - report modified, missing and extra files of installed packages,
+    packages without a saved manifest fail verification, unless -allow-missing-manifest is set
+
+pm -sbom <filename> [-out <directory>] - write SPDX 2.3 (<name>.spdx.json) and CycloneDX (<name>.cdx.json)
+    bills of materials of resolved packages
+
+pm -tree <filename|name@version> [-format text|dot] - print resolved dependency tree
+
+pm -why <package> [-from <filename|name@version>] [-format text|dot] - print every path which leads to a package`
 
 const succeededPrompt = `Operation is successful.`
 
@@ -25,70 +64,314 @@ type Command interface {
 }
 
 func main() {
-    var command Command
+    // Commands are constructed after parsing all flags,
+    // so options could be listed in any order
+    var makeCommand func() Command
     var file *os.File
-    // Not the best method to parse commands 
-    // (cobra package could be used instead of this and validator functions could be extracted), 
-    // but it makes development easier
-    flag.Func("create", "Upload package to the server", func(s string) error {
+    setCommand := func(m func() Command) error {
         // not the best way to limit number of command
-        if command != nil {
+        if makeCommand != nil {
             return errors.New("Expected only 1 command at a time")
-        } 
+        }
 
+        makeCommand = m
+        return nil
+    }
+
+    format := flag.String("format", treecmd.FormatText, "Output format of -tree and -why (text or dot) and of -outdated and -audit (text or json)")
+    out := flag.String("out", ".", "Output directory of -sbom and -pack")
+    list := flag.Bool("list", false, "Print files of a package with their sizes instead of packing it")
+    from := flag.String("from", "./packages.json", "Package description file or name@version inspected by -why")
+    allowMissingDeps := flag.Bool("allow-missing-deps", false, "Publish a package even if some of its dependencies cannot be resolved")
+    force := flag.Bool("force", false, "Overwrite already published version of a package")
+    allowUnsigned := flag.Bool("allow-unsigned", false, "Extract unsigned packages and packages signed by untrusted keys")
+    allowMissingManifest := flag.Bool("allow-missing-manifest", false, "Skip packages installed without a manifest in -verify")
+    advisories := flag.String("advisories", "", "Advisory database checked by -audit and -update (advisories.json in the configuration directory by default)")
+    installedOnly := flag.Bool("installed", false, "Audit installed versions instead of resolving packages on the server")
+    severity := flag.String("severity", "low", "Lowest severity of an advisory which fails -audit (low, medium, high or critical)")
+    var with, without []string
+    flag.Func("with", "Comma separated dependency groups (dev, build, optional) to fetch in addition to runtime dependencies", func(s string) error {
+        with = append(with, splitList(s)...)
+        return nil
+    })
+    flag.Func("without", "Comma separated dependency groups which should not be fetched", func(s string) error {
+        without = append(without, splitList(s)...)
+        return nil
+    })
+    groups := func() resolver.Groups {
+        g, err := resolver.NewGroups(with, without)
+        if err != nil {
+            exitWithError(err)
+        }
+
+        return g
+    }
+    // Not the best method to parse commands
+    // (cobra package could be used instead of this and validator functions could be extracted),
+    // but it makes development easier
+    flag.Func("create", "Upload package to the server", func(s string) error {
         if err := validatePath(s); err != nil {
             return err
         }
-        
-        f, err := os.Open(s)
-        if err != nil {
-            return fmt.Errorf("Failed to open file %s", s)
+
+        return setCommand(func() Command {
+            f, err := os.Open(s)
+            if err != nil {
+                exitWithError(fmt.Errorf("Failed to open file %s", s))
+            }
+
+            file = f
+            cfg := loadConfig()
+            return createcmd.NewCreateCommand(f, createcmd.Options{
+                AllowMissingDeps:  *allowMissingDeps,
+                SigningKey:        cfg.SigningKey,
+                RequireSigningKey: cfg.SigningKeyDeclared,
+                Force:             *force,
+            })
+        })
+    })
+    flag.Func("pack", "Build package archive and metadata locally without uploading them", func(s string) error {
+        if err := validatePath(s); err != nil {
+            return err
         }
-        
-        file = f
-        command = createcmd.NewCreateCommand(f)
 
-        return nil
+        return setCommand(func() Command {
+            f, err := os.Open(s)
+            if err != nil {
+                exitWithError(fmt.Errorf("Failed to open file %s", s))
+            }
+
+            file = f
+            opts := createcmd.PackOptions{
+                Out:  *out,
+                List: *list,
+            }
+            if !*list {
+                cfg := loadConfig()
+                opts.SigningKey = cfg.SigningKey
+                opts.RequireSigningKey = cfg.SigningKeyDeclared
+            }
+            return createcmd.NewPackCommand(f, opts)
+        })
+    })
+    flag.Func("publish", "Upload a package packed by -pack", func(s string) error {
+        if err := validateDirectory(s); err != nil {
+            return err
+        }
+
+        return setCommand(func() Command {
+            return createcmd.NewPublishCommand(s, createcmd.Options{
+                AllowMissingDeps: *allowMissingDeps,
+                TrustedKeys:      loadConfig().TrustedKeys,
+                Force:            *force,
+            })
+        })
+    })
+    flag.Func("excluded", "List files excluded from a package and rules excluding them", func(s string) error {
+        if err := validatePath(s); err != nil {
+            return err
+        }
+
+        return setCommand(func() Command {
+            f, err := os.Open(s)
+            if err != nil {
+                exitWithError(fmt.Errorf("Failed to open file %s", s))
+            }
+
+            file = f
+            return excludedcmd.NewExcludedCommand(f)
+        })
     })
     flag.Func("update", "Fetch specified packages from the server", func (s string) error {
-        if command != nil {
-            return errors.New("Expected only 1 command at a time")
-        } 
+        if err := validatePath(s); err != nil {
+            return err
+        }
+
+        return setCommand(func() Command {
+            f, err := os.Open(s)
+            if err != nil {
+                exitWithError(fmt.Errorf("Failed to open file %s", s))
+            }
 
+            file = f
+            cfg := loadConfig()
+            return updatecmd.NewUpdateCommand(f, nameWithoutExtension(s), updatecmd.Options{
+                Groups:        groups(),
+                TrustedKeys:   cfg.TrustedKeys,
+                AllowUnsigned: *allowUnsigned,
+                Limits:        extractLimits(cfg),
+                Advisories:    advisoriesPath(cfg, *advisories),
+            })
+        })
+    })
+    flag.Func("outdated", "Compare installed packages with versions available on the server", func(s string) error {
         if err := validatePath(s); err != nil {
             return err
         }
 
-        f, err := os.Open(s)
-        if err != nil {
-            return fmt.Errorf("Failed to open file %s", s)
+        return setCommand(func() Command {
+            f, err := os.Open(s)
+            if err != nil {
+                exitWithError(fmt.Errorf("Failed to open file %s", s))
+            }
+
+            file = f
+            return outdatedcmd.NewOutdatedCommand(f, nameWithoutExtension(s), *format, groups())
+        })
+    })
+    flag.Func("audit", "Check packages against the advisory database", func(s string) error {
+        if err := validatePath(s); err != nil {
+            return err
         }
-        
-        file = f
-
-        var nameWithoutExtension string = "packet"
-        candidate := strings.TrimLeft(filepath.Base(s), ".") 
-        fstDot := strings.Index(candidate, ".")
-        if fstDot != -1 && len(candidate[:fstDot]) != 0 {
-            nameWithoutExtension = candidate[:fstDot]
+
+        return setCommand(func() Command {
+            threshold, err := advisory.ParseSeverity(*severity)
+            if err != nil {
+                exitWithError(err)
+            }
+
+            f, err := os.Open(s)
+            if err != nil {
+                exitWithError(fmt.Errorf("Failed to open file %s", s))
+            }
+
+            file = f
+            return auditcmd.NewAuditCommand(f, nameWithoutExtension(s), auditcmd.Options{
+                Advisories: advisoriesPath(loadConfig(), *advisories),
+                Threshold:  threshold,
+                Installed:  *installedOnly,
+                Groups:     groups(),
+                Format:     *format,
+            })
+        })
+    })
+    flag.Func("verify", "Report modified, missing and extra files of packages installed from a package description file", func(s string) error {
+        if err := validatePath(s); err != nil {
+            return err
         }
-        command = updatecmd.NewUpdateCommand(f, nameWithoutExtension)
 
-        return nil
+        return setCommand(func() Command {
+            return verifycmd.NewVerifyCommand(nameWithoutExtension(s), verifycmd.Options{
+                AllowMissingManifest: *allowMissingManifest,
+            })
+        })
+    })
+    flag.Func("sbom", "Write SPDX and CycloneDX bills of materials of packages resolved from a package description file", func(s string) error {
+        if err := validatePath(s); err != nil {
+            return err
+        }
+
+        return setCommand(func() Command {
+            f, err := os.Open(s)
+            if err != nil {
+                exitWithError(fmt.Errorf("Failed to open file %s", s))
+            }
+
+            file = f
+            return sbomcmd.NewSBOMCommand(f, nameWithoutExtension(s), *out, groups())
+        })
+    })
+    flag.Func("tree", "Print resolved dependency tree of a package description file or of a published package (name@version)", func(s string) error {
+        if err := validateSource(s); err != nil {
+            return err
+        }
+
+        return setCommand(func() Command {
+            return treecmd.NewTreeCommand(s, *format, groups())
+        })
+    })
+    flag.Func("why", "Print every path in the dependency tree which leads to a package", func(s string) error {
+        return setCommand(func() Command {
+            if err := validateSource(*from); err != nil {
+                exitWithError(err)
+            }
+
+            return treecmd.NewWhyCommand(s, *from, *format, groups())
+        })
     })
     flag.Parse()
 
-    if command == nil {
+    if makeCommand == nil {
         fmt.Println(helpPrompt)
         return
     }
-    defer file.Close()
-    
-    if err := command.Execute(context.Background()); err != nil {
-        fmt.Println(err)
-    } else {
-        fmt.Println(succeededPrompt)
+    command := makeCommand()
+
+    // Status is written to stderr, so output of reporting commands could be piped
+    err := command.Execute(context.Background())
+    if file != nil {
+        file.Close()
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, err)
+        os.Exit(1)
+    }
+
+    fmt.Fprintln(os.Stderr, succeededPrompt)
+}
+
+func loadConfig() *config.Config {
+    cfg, err := config.Load()
+    if err != nil {
+        exitWithError(fmt.Errorf("%v: %s", err, config.Path()))
+    }
+
+    return cfg
+}
+
+// extractLimits overrides default limits with configured ones
+func extractLimits(cfg *config.Config) archiver.Limits {
+    limits := archiver.DefaultLimits
+    if cfg.ExtractLimits.MaxTotalSize != 0 {
+        limits.MaxTotalSize = cfg.ExtractLimits.MaxTotalSize
+    }
+
+    if cfg.ExtractLimits.MaxFiles != 0 {
+        limits.MaxFiles = cfg.ExtractLimits.MaxFiles
+    }
+
+    if cfg.ExtractLimits.MaxCompressionRatio != 0 {
+        limits.MaxCompressionRatio = cfg.ExtractLimits.MaxCompressionRatio
+    }
+
+    return limits
+}
+
+// advisoriesPath prefers the advisory database given on the command line
+func advisoriesPath(cfg *config.Config, flagValue string) string {
+    if flagValue != "" {
+        return flagValue
+    }
+
+    return cfg.Advisories
+}
+
+func exitWithError(err error) {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(2)
+}
+
+func splitList(s string) []string {
+    items := make([]string, 0)
+    for _, item := range strings.Split(s, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
     }
+
+    return items
+}
+
+// nameWithoutExtension returns name of the directory to which packages listed in the file are installed
+func nameWithoutExtension(s string) string {
+    var name string = "packet"
+    candidate := strings.TrimLeft(filepath.Base(s), ".")
+    fstDot := strings.Index(candidate, ".")
+    if fstDot != -1 && len(candidate[:fstDot]) != 0 {
+        name = candidate[:fstDot]
+    }
+
+    return name
 }
 
 // Validating files should have been performed in commands constructor,
@@ -103,7 +386,7 @@ func validatePath(s string) error {
 
         return fmt.Errorf("Failed to check information about file %s", s)
     }
-    
+
     if !fileInfo.Mode().IsRegular() {
         return errors.New("Unsupported file type")
     }
@@ -111,3 +394,28 @@ func validatePath(s string) error {
     return nil
 }
 
+func validateDirectory(s string) error {
+    fileInfo, err := os.Stat(s)
+    if err != nil {
+        if errors.Is(err, fs.ErrNotExist) {
+            return fmt.Errorf("Could not find directory %s", s)
+        }
+
+        return fmt.Errorf("Failed to check information about directory %s", s)
+    }
+
+    if !fileInfo.IsDir() {
+        return fmt.Errorf("%s is not a directory", s)
+    }
+
+    return nil
+}
+
+// validateSource accepts either a package description file or a published package reference
+func validateSource(s string) error {
+    if _, err := os.Lstat(s); err != nil && strings.Contains(s, "@") {
+        return nil
+    }
+
+    return validatePath(s)
+}
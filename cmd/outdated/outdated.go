@@ -0,0 +1,174 @@
+package outdatedcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/Elementary1092/pm/internal/adapter/pmssh"
+	"github.com/Elementary1092/pm/internal/installed"
+	"github.com/Elementary1092/pm/internal/packet/parser"
+	"github.com/Elementary1092/pm/internal/packet/resolver"
+	"github.com/Elementary1092/pm/internal/repository"
+	"github.com/Elementary1092/pm/internal/version"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported output format")
+	ErrOutdatedPackages  = errors.New("some packages are outdated")
+)
+
+// Report describes the state of a single package listed in a package description file.
+// Empty Installed means that the package is not installed;
+// empty Wanted means that no published version satisfies the constraint.
+type Report struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint"`
+	Installed  string `json:"installed"`
+	Wanted     string `json:"wanted"`
+	Latest     string `json:"latest"`
+	Outdated   bool   `json:"outdated"`
+}
+
+type outdatedCommand struct {
+	data   io.Reader
+	name   string
+	format string
+	groups resolver.Groups
+	out    io.Writer
+}
+
+// NewOutdatedCommand compares packages installed by update command with published versions.
+// name is the same directory name which is used by update command,
+// groups are the dependency groups update installs (only runtime dependencies if groups is nil).
+func NewOutdatedCommand(data io.Reader, name string, format string, groups resolver.Groups) *outdatedCommand {
+	if data == nil {
+		return nil
+	}
+
+	if groups == nil {
+		groups = resolver.DefaultGroups()
+	}
+
+	return &outdatedCommand{
+		data:   data,
+		name:   name,
+		format: format,
+		groups: groups,
+		out:    os.Stdout,
+	}
+}
+
+func (od *outdatedCommand) Execute(ctx context.Context) error {
+	if od.format != FormatText && od.format != FormatJSON {
+		return ErrUnsupportedFormat
+	}
+
+	description, err := parser.ParsePackage(od.data)
+	if err != nil {
+		return err
+	}
+
+	if err := pmssh.Connect(ctx); err != nil {
+		return err
+	}
+	defer pmssh.Close(ctx)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		wd = "."
+	}
+	installRoot := filepath.Join(wd, od.name)
+
+	repo := repository.NewRemote()
+	reports := make([]Report, 0, len(description.Packages))
+	anyOutdated := false
+	for _, pack := range description.Packages {
+		versions, err := repo.Versions(ctx, pack.Name)
+		if err != nil {
+			return fmt.Errorf("failed to list versions of a package '%s'", pack.Name)
+		}
+
+		report := Report{
+			Name:       pack.Name,
+			Constraint: pack.Version,
+			Wanted:     version.Latest(versions, pack.Version),
+			Latest:     version.Latest(versions, ""),
+		}
+		if record, err := installed.Load(installRoot, pack.Name); err == nil {
+			report.Installed = record.Version
+		} else if !errors.Is(err, installed.ErrNotInstalled) {
+			return err
+		}
+		report.Outdated = isOutdated(report.Installed, report.Latest, od.groups.Includes(pack))
+		anyOutdated = anyOutdated || report.Outdated
+
+		reports = append(reports, report)
+	}
+
+	if od.format == FormatJSON {
+		err = writeJSON(od.out, reports)
+	} else {
+		err = writeTable(od.out, reports)
+	}
+	if err != nil {
+		return err
+	}
+
+	if anyOutdated {
+		return ErrOutdatedPackages
+	}
+
+	return nil
+}
+
+// isOutdated reports whether a newer release than the installed one is published.
+// Packages which are not installed are outdated if anything is published,
+// unless update does not install them because their group is not selected.
+func isOutdated(installedVersion string, latest string, selected bool) bool {
+	if latest == "" {
+		return false
+	}
+
+	if installedVersion == "" {
+		return selected
+	}
+
+	cmp, err := version.CompareVersions(installedVersion, latest)
+	return err != nil || cmp == version.Less
+}
+
+func writeJSON(w io.Writer, reports []Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(reports)
+}
+
+func writeTable(w io.Writer, reports []Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Package\tConstraint\tInstalled\tWanted\tLatest\t")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n",
+			r.Name, orDash(r.Constraint), orDash(r.Installed), orDash(r.Wanted), orDash(r.Latest))
+	}
+
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
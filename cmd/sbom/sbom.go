@@ -0,0 +1,133 @@
+package sbomcmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Elementary1092/pm/internal/adapter/pmssh"
+	"github.com/Elementary1092/pm/internal/packet/parser"
+	"github.com/Elementary1092/pm/internal/packet/resolver"
+	"github.com/Elementary1092/pm/internal/repository"
+	"github.com/Elementary1092/pm/internal/sbom"
+)
+
+var (
+	ErrFailedToCreateOutput = errors.New("failed to create bill of materials file")
+)
+
+type sbomCommand struct {
+	data   io.Reader
+	name   string
+	outDir string
+	groups resolver.Groups
+}
+
+// NewSBOMCommand writes SPDX and CycloneDX documents describing the resolved packages
+// to <outDir>/<name>.spdx.json and <outDir>/<name>.cdx.json.
+// Only runtime dependencies are described if groups is nil.
+func NewSBOMCommand(data io.Reader, name string, outDir string, groups resolver.Groups) *sbomCommand {
+	if data == nil {
+		return nil
+	}
+
+	if groups == nil {
+		groups = resolver.DefaultGroups()
+	}
+
+	return &sbomCommand{
+		data:   data,
+		name:   name,
+		outDir: outDir,
+		groups: groups,
+	}
+}
+
+func (sb *sbomCommand) Execute(ctx context.Context) error {
+	fmt.Println("Parsing package description.")
+	description, err := parser.ParsePackage(sb.data)
+	if err != nil {
+		return err
+	}
+
+	if err := pmssh.Connect(ctx); err != nil {
+		return err
+	}
+	defer pmssh.Close(ctx)
+
+	fmt.Println("Resolving packages.")
+	repo := repository.NewRemote()
+	graph, err := resolver.Resolve(ctx, repo, description.Packages, sb.groups)
+	if err != nil {
+		return err
+	}
+
+	input, err := makeInput(ctx, repo, sb.name, graph)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(sb.outDir, os.ModePerm); err != nil {
+		return ErrFailedToCreateOutput
+	}
+
+	spdxPath := filepath.Join(sb.outDir, sb.name+".spdx.json")
+	if err := writeDocument(spdxPath, input, sbom.WriteSPDX); err != nil {
+		return err
+	}
+
+	cdxPath := filepath.Join(sb.outDir, sb.name+".cdx.json")
+	if err := writeDocument(cdxPath, input, sbom.WriteCycloneDX); err != nil {
+		return err
+	}
+
+	fmt.Println("Bill of materials is written to", spdxPath, "and", cdxPath)
+
+	return nil
+}
+
+// makeInput takes checksums and licenses from the metadata of each resolved package
+func makeInput(ctx context.Context, repo *repository.Remote, name string, graph *resolver.Graph) (*sbom.Input, error) {
+	input := &sbom.Input{
+		Name:    name,
+		Created: time.Now(),
+	}
+
+	for _, root := range graph.Roots {
+		input.Roots = append(input.Roots, root.To.Name)
+	}
+
+	for _, node := range graph.Nodes {
+		meta, err := repo.Metadata(ctx, node.Name, node.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		pack := sbom.Package{
+			Name:    node.Name,
+			Version: node.Version,
+			SHA256:  meta.Checksum,
+			License: meta.License,
+		}
+		for _, dep := range node.Deps {
+			pack.DependsOn = append(pack.DependsOn, dep.To.Name)
+		}
+		input.Packages = append(input.Packages, pack)
+	}
+
+	return input, nil
+}
+
+func writeDocument(filePath string, input *sbom.Input, write func(io.Writer, *sbom.Input) error) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return ErrFailedToCreateOutput
+	}
+	defer f.Close()
+
+	return write(f, input)
+}
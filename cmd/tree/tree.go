@@ -0,0 +1,157 @@
+package treecmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Elementary1092/pm/internal/adapter/pmssh"
+	"github.com/Elementary1092/pm/internal/packet/parser"
+	"github.com/Elementary1092/pm/internal/packet/resolver"
+	"github.com/Elementary1092/pm/internal/repository"
+)
+
+const (
+	FormatText = "text"
+	FormatDot  = "dot"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported output format")
+	ErrFailedToOpenFile  = errors.New("failed to open package description file")
+	ErrPackageNotFound   = errors.New("package is not in the dependency tree")
+)
+
+type treeCommand struct {
+	source string
+	format string
+	groups resolver.Groups
+	out    io.Writer
+}
+
+// NewTreeCommand prints the resolved dependency tree of source.
+// source is either a package description file or a published package reference (name@version).
+// Only runtime dependencies are resolved if groups is nil.
+func NewTreeCommand(source string, format string, groups resolver.Groups) *treeCommand {
+	if source == "" {
+		return nil
+	}
+
+	if groups == nil {
+		groups = resolver.DefaultGroups()
+	}
+
+	return &treeCommand{
+		source: source,
+		format: format,
+		groups: groups,
+		out:    os.Stdout,
+	}
+}
+
+func (tr *treeCommand) Execute(ctx context.Context) error {
+	if tr.format != FormatText && tr.format != FormatDot {
+		return ErrUnsupportedFormat
+	}
+
+	graph, err := resolveSource(ctx, tr.source, tr.groups)
+	if err != nil {
+		return err
+	}
+
+	if tr.format == FormatDot {
+		return graph.WriteDot(tr.out, tr.source)
+	}
+
+	return graph.WriteTree(tr.out, tr.source)
+}
+
+type whyCommand struct {
+	pack   string
+	source string
+	format string
+	groups resolver.Groups
+	out    io.Writer
+}
+
+// NewWhyCommand prints every path in the dependency tree of source which leads to pack
+func NewWhyCommand(pack string, source string, format string, groups resolver.Groups) *whyCommand {
+	if pack == "" || source == "" {
+		return nil
+	}
+
+	if groups == nil {
+		groups = resolver.DefaultGroups()
+	}
+
+	return &whyCommand{
+		pack:   pack,
+		source: source,
+		format: format,
+		groups: groups,
+		out:    os.Stdout,
+	}
+}
+
+func (wh *whyCommand) Execute(ctx context.Context) error {
+	if wh.format != FormatText && wh.format != FormatDot {
+		return ErrUnsupportedFormat
+	}
+
+	graph, err := resolveSource(ctx, wh.source, wh.groups)
+	if err != nil {
+		return err
+	}
+
+	paths := graph.Paths(wh.pack)
+	if len(paths) == 0 {
+		return fmt.Errorf("%w: %s", ErrPackageNotFound, wh.pack)
+	}
+
+	if wh.format == FormatDot {
+		return resolver.WritePathsDot(wh.out, wh.source, paths)
+	}
+
+	return resolver.WritePaths(wh.out, wh.source, paths)
+}
+
+func resolveSource(ctx context.Context, source string, groups resolver.Groups) (*resolver.Graph, error) {
+	roots, err := readRoots(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pmssh.Connect(ctx); err != nil {
+		return nil, err
+	}
+	defer pmssh.Close(ctx)
+
+	return resolver.Resolve(ctx, repository.NewRemote(), roots, groups)
+}
+
+func readRoots(source string) ([]parser.PackageDescription, error) {
+	if _, err := os.Lstat(source); err != nil && strings.Contains(source, "@") {
+		ref, err := parser.ParseReference(source)
+		if err != nil {
+			return nil, err
+		}
+
+		return []parser.PackageDescription{*ref}, nil
+	}
+
+	f, err := os.Open(source)
+	if err != nil {
+		return nil, ErrFailedToOpenFile
+	}
+	defer f.Close()
+
+	description, err := parser.ParsePackage(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return description.Packages, nil
+}
@@ -1,37 +1,73 @@
 package updatecmd
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 
 	"github.com/Elementary1092/pm/internal/adapter/pmssh"
+	"github.com/Elementary1092/pm/internal/advisory"
 	"github.com/Elementary1092/pm/internal/directory"
+	"github.com/Elementary1092/pm/internal/installed"
 	"github.com/Elementary1092/pm/internal/packet/archiver"
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+	"github.com/Elementary1092/pm/internal/packet/delta"
+	"github.com/Elementary1092/pm/internal/packet/manifest"
+	"github.com/Elementary1092/pm/internal/packet/metadata"
 	"github.com/Elementary1092/pm/internal/packet/parser"
-	"github.com/Elementary1092/pm/internal/version"
+	"github.com/Elementary1092/pm/internal/packet/resolver"
+	"github.com/Elementary1092/pm/internal/packet/signature"
+	"github.com/Elementary1092/pm/internal/repository"
 )
 
 var (
     ErrFailedToCreateDestinationDir = errors.New("failed to create destination directory")
+    ErrUnsignedPackage              = errors.New("package is not signed")
+    ErrFailedToInstallPackage       = errors.New("failed to move extracted package in place")
 )
 
+// Options change the way packages are fetched
+type Options struct {
+    // Groups of dependencies to fetch. Only runtime dependencies are fetched if Groups is nil.
+    Groups resolver.Groups
+    // TrustedKeys is a path to the file with public keys of trusted publishers
+    TrustedKeys string
+    // AllowUnsigned extracts unsigned packages and packages signed by unknown keys
+    AllowUnsigned bool
+    // Limits of extracted archives. archiver.DefaultLimits are used if Limits is zero.
+    Limits archiver.Limits
+    // Advisories is a path to the advisory database. Installed versions affected by advisories are reported.
+    Advisories string
+}
+
 type updateCommand struct {
     data io.Reader
     name string
+    opts Options
 }
 
-func NewUpdateCommand(data io.Reader, name string) *updateCommand {
+func NewUpdateCommand(data io.Reader, name string, opts Options) *updateCommand {
     if data == nil {
         return nil
     }
 
+    if opts.Groups == nil {
+        opts.Groups = resolver.DefaultGroups()
+    }
+
+    if opts.Limits == (archiver.Limits{}) {
+        opts.Limits = archiver.DefaultLimits
+    }
+
     return &updateCommand{
         data: data,
         name: name,
+        opts: opts,
     }
 }
 
@@ -43,6 +79,16 @@ func (up *updateCommand) Execute(ctx context.Context) error {
         return err
     }
 
+    keyring, err := up.loadKeyring()
+    if err != nil {
+        return err
+    }
+
+    advisories, err := up.loadAdvisories()
+    if err != nil {
+        return err
+    }
+
     err = pmssh.Connect(ctx)
     if err != nil {
         return err
@@ -61,106 +107,346 @@ func (up *updateCommand) Execute(ctx context.Context) error {
         return ErrFailedToCreateDestinationDir
     }
 
-    for _, pack := range description.Packages {
-        versionToGet := pack.Version
+    repo := repository.NewRemote()
+    for _, pack := range up.opts.Groups.Filter(description.Packages) {
+        versionToGet, err := resolver.SelectVersion(ctx, repo, pack.Name, pack.Version)
+        if err != nil {
+            constraint := pack.Version
+            if constraint == "" {
+                constraint = "latest"
+            }
+            return fmt.Errorf("failed to find satisfying version ('%s') of a package '%s'", constraint, pack.Name)
+        }
 
-        var err error
-        if versionToGet == "" {
-            versionToGet, err = up.getLatest(ctx, pack.Name)
+        fmt.Printf("Fetching package '%s' of version '%s'\n", pack.Name, versionToGet)
+        metaFile, err := repo.MetadataFile(ctx, pack.Name, versionToGet)
+        if err != nil {
+            return err
+        }
+
+        meta, err := metadata.Decode(bytes.NewReader(metaFile))
+        if err != nil {
+            return err
+        }
+
+        format := meta.ArchiveFormat()
+        remoteArchName := directory.MakeRemoteArchiveName(pack.Name, versionToGet, pack.Name+format.Extension())
+        verifySignature := func(archiveDigest checksum.Digest) error {
+            payload := signature.Payload{
+                Name:     pack.Name,
+                Version:  versionToGet,
+                Archive:  archiveDigest,
+                Metadata: metaFile,
+            }
+            if err := up.verifySignature(ctx, repo, keyring, payload, format); err != nil {
+                return fmt.Errorf("refusing to extract package '%s' of version '%s': %w", pack.Name, versionToGet, err)
+            }
+
+            return nil
+        }
+        verify := func(digest checksum.Digest) error {
+            if err := verifyDigest(meta, digest); err != nil {
+                return fmt.Errorf("refusing to extract package '%s' of version '%s': %w", pack.Name, versionToGet, err)
+            }
+
+            return verifySignature(digest)
+        }
+
+        // files become visible only after the archive is verified
+        staging := directory.MakeInstalledStagingDirectory(packDestination, pack.Name)
+        var packManifest *manifest.Manifest
+        if deltaApplies(packDestination, pack.Name, meta) {
+            fmt.Printf("Updating package '%s' from version '%s' with a delta\n", pack.Name, meta.Delta.From)
+            packManifest, err = up.updateFromDelta(ctx, repo, pack.Name, versionToGet, meta, packDestination, tempPath, verifySignature)
             if err != nil {
-                return fmt.Errorf("failed to find satisfying version ('lastest') of a package '%s'", pack.Name)
+                fmt.Println("Warning: delta is not applied, fetching the full archive:", err)
+                os.RemoveAll(staging)
+            }
+        }
+
+        if packManifest == nil {
+            if format.Streamable() {
+                fmt.Println("Streaming and extracting package", pack.Name)
+                packManifest, err = up.streamPackage(ctx, remoteArchName, format, staging, verify)
+            } else {
+                archNamePath := filepath.Join(directory.MakeArchivePathName(tempPath, pack.Name, versionToGet), pack.Name+format.Extension())
+                packManifest, err = up.downloadPackage(ctx, remoteArchName, archNamePath, staging, verify)
             }
-        } else {
-            versionToGet, err = up.getSpecificVersion(ctx, pack.Name, versionToGet)
             if err != nil {
-                return fmt.Errorf("failed to find satisfying version ('%s') of a package '%s'", pack.Version, pack.Name)
+                os.RemoveAll(staging)
+                return err
             }
         }
 
-        fmt.Printf("Fetching package '%s' of version '%s'\n", pack.Name, versionToGet)
-        archPath := directory.MakeArchivePathName(tempPath, pack.Name, versionToGet)
-        archNamePath := filepath.Join(archPath, pack.Name+".zip")
-        if err := os.MkdirAll(archPath, os.ModePerm); err != nil {
-            return ErrFailedToCreateDestinationDir
+        if err := replacePackage(packDestination, pack.Name); err != nil {
+            return err
         }
 
-        remoteArchName := directory.MakeRemoteArchiveName(pack.Name, versionToGet, pack.Name)
+        if err := saveManifest(packManifest, packDestination, pack.Name); err != nil {
+            return err
+        }
 
-        err = pmssh.Download(ctx, remoteArchName, archNamePath)
-        if err != nil {
+        record := &installed.Record{
+            Name:    pack.Name,
+            Version: versionToGet,
+            Group:   pack.DependencyGroup(),
+        }
+        if err := installed.Save(packDestination, record); err != nil {
             return err
         }
 
-        fmt.Println("Extracting package", pack.Name)
-        err = archiver.ExtractFrom(archNamePath, filepath.Join(packDestination, pack.Name))
+        for _, adv := range advisories.Match(pack.Name, versionToGet) {
+            fmt.Printf("Warning: %s@%s is affected by %s (%s): %s\n",
+                pack.Name, versionToGet, adv.ID, adv.Severity, adv.Description)
+        }
+    }
+
+
+    return nil
+}
+
+// streamPackage extracts a tar archive to the staging directory while it is being downloaded.
+// Checksum is computed on the fly and verified after the whole stream is read.
+// Files of an unverified stream stay inside of staging: the extractor refuses entries escaping it
+// directly or through symbolic links extracted before them, and staging is removed if verification fails.
+func (up *updateCommand) streamPackage(ctx context.Context, remoteArchName string, format archiver.Format, staging string, verify func(checksum.Digest) error) (*manifest.Manifest, error) {
+    if err := resetDirectory(staging); err != nil {
+        return nil, err
+    }
+
+    digest := checksum.NewWriter()
+    var packManifest *manifest.Manifest
+    err := pmssh.DownloadStream(ctx, remoteArchName, func(r io.Reader) error {
+        stream := io.TeeReader(r, digest)
+        var err error
+        packManifest, err = archiver.ExtractStream(stream, format, staging, up.opts.Limits)
         if err != nil {
             return err
         }
+
+        // padding after the end of the tar stream is a part of the archive too
+        if _, err := io.Copy(io.Discard, stream); err != nil {
+            return pmssh.ErrFailedToDownloadFile
+        }
+
+        return nil
+    })
+    if err != nil {
+        return nil, err
     }
 
+    if err := verify(digest.Digest()); err != nil {
+        return nil, err
+    }
 
-    return nil
+    return packManifest, nil
 }
 
-func (up *updateCommand) getLatest(ctx context.Context, packName string) (string, error) {
-    lastestLink := directory.MakeLatestArchiveLink(packName)
+// downloadPackage verifies an archive which could not be streamed before extracting it to the staging directory
+func (up *updateCommand) downloadPackage(ctx context.Context, remoteArchName string, archNamePath string, staging string, verify func(checksum.Digest) error) (*manifest.Manifest, error) {
+    if err := os.MkdirAll(filepath.Dir(archNamePath), os.ModePerm); err != nil {
+        return nil, ErrFailedToCreateDestinationDir
+    }
+
+    if err := pmssh.Download(ctx, remoteArchName, archNamePath); err != nil {
+        return nil, err
+    }
 
-    filePath, err := pmssh.ResolveLink(ctx, lastestLink)
+    digest, err := checksum.ComputeFile(archNamePath)
     if err != nil {
-        return "", err
+        return nil, err
     }
 
-    return directory.ExtractVersionFromRemoteFilePath(filePath), nil
+    if err := verify(digest); err != nil {
+        return nil, err
+    }
+
+    fmt.Println("Extracting package", filepath.Base(archNamePath))
+    if err := resetDirectory(staging); err != nil {
+        return nil, err
+    }
+
+    if err := archiver.ExtractFromWithLimits(archNamePath, staging, up.opts.Limits); err != nil {
+        return nil, err
+    }
+
+    packManifest, err := archiver.ReadManifest(archNamePath)
+    if errors.Is(err, archiver.ErrNoManifest) {
+        return nil, nil
+    }
+
+    return packManifest, err
 }
 
-// Assumes that all versions are correct
-func (up *updateCommand) getSpecificVersion(ctx context.Context, packName string, ver string) (string, error) {
-    versionType := version.Type(ver)
-    ver = version.Clean(ver)
-    if versionType == version.LessOrEqual {
-        ver = up.findLessOrEqualVersion(ctx, packName, ver)
-    } else if versionType == version.GreaterOrEqual {
-        ver = up.findGreaterOrEqualVersion(ctx, packName, ver)
+// deltaApplies reports whether the installed version is the one the published delta was created against
+func deltaApplies(root string, packName string, meta *metadata.Metadata) bool {
+    if meta.Delta == nil {
+        return false
+    }
+
+    if _, ok := meta.Digest(); !ok {
+        return false
     }
-        
-    if ver == "" {
-        return "", errors.New("no satisfying version")
+
+    record, err := installed.Load(root, packName)
+    if err != nil {
+        return false
     }
 
-        
-    return ver, nil
+    return record.Version == meta.Delta.From
 }
 
-// Assumes that user provided version is present in the server
-func (up *updateCommand) findLessOrEqualVersion(ctx context.Context, packName string, maxVer string) string {
-    lastestVersion, err := up.getLatest(ctx, packName)
+// updateFromDelta assembles the package in the staging directory from the installed version and the delta archive.
+// Delta checksum is a part of the signed metadata, so the signature is verified against the full archive checksum.
+// Manifest of the whole package is returned.
+func (up *updateCommand) updateFromDelta(ctx context.Context, repo *repository.Remote, packName string, ver string, meta *metadata.Metadata, root string, tempPath string, verifySignature func(checksum.Digest) error) (*manifest.Manifest, error) {
+    next, err := repo.Manifest(ctx, packName, ver, meta)
     if err != nil {
-        return ""
+        return nil, err
+    }
+
+    verify := func(digest checksum.Digest) error {
+        if err := meta.Delta.Digest().Verify(digest); err != nil {
+            return fmt.Errorf("refusing to extract delta of package '%s' of version '%s': %w", packName, ver, err)
+        }
+
+        archiveDigest, _ := meta.Digest()
+        return verifySignature(archiveDigest)
     }
 
-    verType, err := version.CompareVersions(maxVer, lastestVersion)
+    format := meta.ArchiveFormat()
+    remoteDeltaName := directory.MakeRemoteDeltaName(packName, ver, meta.Delta.From, format.Extension())
+    staging := directory.MakeInstalledStagingDirectory(root, packName)
+    if format.Streamable() {
+        _, err = up.streamPackage(ctx, remoteDeltaName, format, staging, verify)
+    } else {
+        deltaPath := filepath.Join(directory.MakeArchivePathName(tempPath, packName, ver), filepath.Base(remoteDeltaName))
+        _, err = up.downloadPackage(ctx, remoteDeltaName, deltaPath, staging, verify)
+    }
     if err != nil {
-        return ""
+        return nil, err
+    }
+
+    if err := delta.Apply(filepath.Join(root, packName), staging, next); err != nil {
+        return nil, err
+    }
+
+    return next, nil
+}
+
+// verifyDigest compares downloaded archive with the checksum stored in the package metadata.
+// Packages published before checksums were introduced are extracted with a warning.
+func verifyDigest(meta *metadata.Metadata, actual checksum.Digest) error {
+    expected, ok := meta.Digest()
+    if !ok {
+        fmt.Println("Warning: package has no checksum, integrity is not verified")
+        return nil
+    }
+
+    return expected.Verify(actual)
+}
+
+func resetDirectory(dir string) error {
+    if err := os.RemoveAll(dir); err != nil {
+        return ErrFailedToCreateDestinationDir
+    }
+
+    if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+        return ErrFailedToCreateDestinationDir
+    }
+
+    return nil
+}
+
+// replacePackage moves verified files from the staging directory in place of the installed package,
+// so files of the previous version do not remain
+func replacePackage(root string, packName string) error {
+    staging := directory.MakeInstalledStagingDirectory(root, packName)
+    previous := directory.MakeInstalledPreviousDirectory(root, packName)
+    packDir := filepath.Join(root, packName)
+
+    os.RemoveAll(previous)
+    if err := os.Rename(packDir, previous); err != nil && !errors.Is(err, fs.ErrNotExist) {
+        return ErrFailedToInstallPackage
+    }
+
+    if err := os.Rename(staging, packDir); err != nil {
+        os.Rename(previous, packDir)
+        return ErrFailedToInstallPackage
+    }
+
+    os.RemoveAll(previous)
+
+    return nil
+}
+
+// loadKeyring returns an empty keyring if there is no trusted keys file,
+// so that every signed package is rejected as signed by an unknown key
+func (up *updateCommand) loadKeyring() (*signature.Keyring, error) {
+    if up.opts.TrustedKeys == "" {
+        return signature.ParseKeyring(nil)
+    }
+
+    if _, err := os.Stat(up.opts.TrustedKeys); errors.Is(err, fs.ErrNotExist) {
+        return signature.ParseKeyring(nil)
+    }
+
+    return signature.LoadKeyring(up.opts.TrustedKeys)
+}
+
+// loadAdvisories returns an empty database if there is no advisory database
+func (up *updateCommand) loadAdvisories() (*advisory.Database, error) {
+    if up.opts.Advisories == "" {
+        return &advisory.Database{}, nil
+    }
+
+    if _, err := os.Stat(up.opts.Advisories); errors.Is(err, fs.ErrNotExist) {
+        return &advisory.Database{}, nil
     }
 
-    if verType == version.Less || verType == version.Exact {
-        return maxVer
+    db, err := advisory.Load(up.opts.Advisories)
+    if err != nil {
+        return nil, fmt.Errorf("%w: %s", err, up.opts.Advisories)
     }
 
-    return lastestVersion
+    return db, nil
 }
 
-func (up *updateCommand) findGreaterOrEqualVersion(ctx context.Context, packName string, minVer string) string {
-    latestVersion, err := up.getLatest(ctx, packName)
+// verifySignature fails closed: unsigned packages and packages signed by unknown keys
+// are rejected unless it is explicitly allowed. Invalid signatures are always rejected.
+func (up *updateCommand) verifySignature(ctx context.Context, repo *repository.Remote, keyring *signature.Keyring, payload signature.Payload, format archiver.Format) error {
+    sig, err := repo.Signature(ctx, payload.Name, payload.Version, format)
     if err != nil {
-        return ""
+        if errors.Is(err, signature.ErrInvalidSignatureFormat) {
+            return err
+        }
+
+        if up.opts.AllowUnsigned {
+            fmt.Println("Warning: package is not signed")
+            return nil
+        }
+
+        return ErrUnsignedPackage
     }
 
-    verType, err := version.CompareVersions(minVer, latestVersion)
-    if verType == version.Greater || err != nil {
-        return ""
+    err = keyring.Verify(sig, payload)
+    if errors.Is(err, signature.ErrUnknownSigner) && up.opts.AllowUnsigned {
+        fmt.Println("Warning:", err)
+        return nil
     }
 
-    return latestVersion
+    return err
 }
 
+// saveManifest keeps manifest of the archive alongside the installed package,
+// so that the installed files could be verified later
+func saveManifest(packManifest *manifest.Manifest, root string, packName string) error {
+    manifestPath := directory.MakeInstalledManifestPathName(root, packName)
+    if packManifest == nil {
+        fmt.Println("Warning: package has no manifest, installed files could not be verified")
+        os.Remove(manifestPath)
+        return nil
+    }
+
+    return packManifest.Save(manifestPath)
+}
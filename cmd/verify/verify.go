@@ -0,0 +1,110 @@
+package verifycmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/Elementary1092/pm/internal/directory"
+	"github.com/Elementary1092/pm/internal/installed"
+	"github.com/Elementary1092/pm/internal/packet/manifest"
+)
+
+var (
+	ErrVerificationFailed = errors.New("installed packages differ from their manifests")
+)
+
+// Options change the way installed packages are verified
+type Options struct {
+	// AllowMissingManifest skips packages installed without a manifest instead of failing verification
+	AllowMissingManifest bool
+}
+
+type verifyCommand struct {
+	name string
+	opts Options
+	out  io.Writer
+}
+
+// NewVerifyCommand checks packages installed by update command to the directory name
+func NewVerifyCommand(name string, opts Options) *verifyCommand {
+	if name == "" {
+		return nil
+	}
+
+	return &verifyCommand{
+		name: name,
+		opts: opts,
+		out:  os.Stdout,
+	}
+}
+
+func (vr *verifyCommand) Execute(ctx context.Context) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		wd = "."
+	}
+	installRoot := filepath.Join(wd, vr.name)
+
+	records, err := installed.LoadAll(installRoot)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, record := range records {
+		fmt.Fprintf(vr.out, "%s@%s: ", record.Name, record.Version)
+
+		// a deleted manifest would hide any modification, so it is skipped only if it is allowed
+		manifestPath := directory.MakeInstalledManifestPathName(installRoot, record.Name)
+		if _, err := os.Stat(manifestPath); errors.Is(err, fs.ErrNotExist) {
+			if vr.opts.AllowMissingManifest {
+				fmt.Fprintln(vr.out, "no manifest, skipped")
+				continue
+			}
+
+			failed = true
+			fmt.Fprintln(vr.out, "FAILED: no manifest")
+			continue
+		}
+
+		packManifest, err := manifest.Load(manifestPath)
+		if err != nil {
+			failed = true
+			fmt.Fprintf(vr.out, "FAILED: %v\n", err)
+			continue
+		}
+
+		report, err := packManifest.Verify(filepath.Join(installRoot, record.Name))
+		if err != nil {
+			return err
+		}
+
+		if report.OK() {
+			fmt.Fprintln(vr.out, "OK")
+			continue
+		}
+
+		failed = true
+		fmt.Fprintln(vr.out, "FAILED")
+		writeFiles(vr.out, "modified", report.Modified)
+		writeFiles(vr.out, "missing", report.Missing)
+		writeFiles(vr.out, "extra", report.Extra)
+	}
+
+	if failed {
+		return ErrVerificationFailed
+	}
+
+	return nil
+}
+
+func writeFiles(w io.Writer, kind string, files []string) {
+	for _, f := range files {
+		fmt.Fprintf(w, "  %s: %s\n", kind, f)
+	}
+}
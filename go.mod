@@ -4,6 +4,8 @@ go 1.19
 
 require (
 	github.com/go-playground/validator/v10 v10.15.4
+	github.com/klauspost/compress v1.16.7
+	github.com/klauspost/pgzip v1.2.6
 	github.com/pkg/sftp v1.13.6
 	go.uber.org/goleak v1.2.1
 	golang.org/x/crypto v0.13.0
@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"errors"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
@@ -50,6 +51,7 @@ var (
 	ErrFailedToUploadFile      = errors.New("failed to upload file")
 	ErrFailedToOpenDestination = errors.New("failed to open destination file")
 	ErrCannotReadDirectory     = errors.New("cannot read directory")
+	ErrDirectoryNotFound       = errors.New("directory does not exist")
 	ErrFailedToDownloadFile    = errors.New("failed to download file")
 )
 
@@ -276,7 +278,7 @@ func doesFileExist(ctx context.Context, remoteFile string) bool {
 
 	_, err := client.Lstat(remoteFile)
 
-	return err != nil
+	return err == nil
 }
 
 func CreateSymbolicLink(ctx context.Context, linkPathName string, linkTo string) error {
@@ -322,3 +324,237 @@ func ResolveLink(ctx context.Context, linkPathName string) (string, error) {
     return path, nil
 }
 
+
+// ListDirectory returns names of entries of the remote directory
+func ListDirectory(ctx context.Context, remoteDir string) ([]string, error) {
+    connMutex.Lock()
+    defer connMutex.Unlock()
+
+    if conn == nil {
+        return nil, ErrNotConnected
+    }
+
+    client := fsClient()
+    if client == nil {
+        return nil, ErrNotConnected
+    }
+
+    entries, err := client.ReadDir(remoteDir)
+    if errors.Is(err, os.ErrNotExist) {
+        return nil, ErrDirectoryNotFound
+    }
+    if err != nil {
+        return nil, ErrCannotReadDirectory
+    }
+
+    names := make([]string, 0, len(entries))
+    for _, entry := range entries {
+        names = append(names, entry.Name())
+    }
+
+    return names, nil
+}
+
+// ReadFile returns the whole content of the remote file
+func ReadFile(ctx context.Context, remoteFilePath string) ([]byte, error) {
+    connMutex.Lock()
+    defer connMutex.Unlock()
+
+    if conn == nil {
+        return nil, ErrNotConnected
+    }
+
+    client := fsClient()
+    if client == nil {
+        return nil, ErrNotConnected
+    }
+
+    src, err := client.Open(remoteFilePath)
+    if err != nil {
+        return nil, ErrFailedToOpenSource
+    }
+    defer src.Close()
+
+    data, err := io.ReadAll(src)
+    if err != nil {
+        return nil, ErrFailedToDownloadFile
+    }
+
+    return data, nil
+}
+
+// AppendToFile appends data to the remote file creating it if needed
+func AppendToFile(ctx context.Context, remoteFilePath string, data []byte) error {
+    connMutex.Lock()
+    defer connMutex.Unlock()
+
+    if conn == nil {
+        return ErrNotConnected
+    }
+
+    client := fsClient()
+    if client == nil {
+        return ErrNotConnected
+    }
+
+    if err := client.MkdirAll(filepath.Dir(remoteFilePath)); err != nil {
+        return ErrFailedToUploadFile
+    }
+
+    dst, err := client.OpenFile(remoteFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND)
+    if err != nil {
+        return ErrFailedToOpenDestination
+    }
+    defer dst.Close()
+
+    if _, err := dst.Write(data); err != nil {
+        return ErrFailedToUploadFile
+    }
+
+    return nil
+}
+
+// posixRenameExtension allows to replace existing files atomically
+const posixRenameExtension = "posix-rename@openssh.com"
+
+// SupportsStreaming reports whether a file could be written directly to the server
+// and atomically moved in place afterwards
+func SupportsStreaming(ctx context.Context) bool {
+    connMutex.Lock()
+    defer connMutex.Unlock()
+
+    if conn == nil {
+        return false
+    }
+
+    client := fsClient()
+    if client == nil {
+        return false
+    }
+
+    _, ok := client.HasExtension(posixRenameExtension)
+    return ok
+}
+
+// UploadStream creates the remote file and passes it to write.
+// Connection is locked while write is running, so write should not call functions of this package.
+func UploadStream(ctx context.Context, dstPath string, write func(w io.Writer) error) error {
+    connMutex.Lock()
+    defer connMutex.Unlock()
+
+    if conn == nil {
+        return ErrNotConnected
+    }
+
+    client := fsClient()
+    if client == nil {
+        return ErrNotConnected
+    }
+
+    if err := client.MkdirAll(filepath.Dir(dstPath)); err != nil {
+        return ErrFailedToUploadFile
+    }
+
+    dst, err := client.Create(dstPath)
+    if err != nil {
+        return ErrFailedToOpenDestination
+    }
+
+    if err := write(dst); err != nil {
+        dst.Close()
+        return err
+    }
+
+    if err := dst.Close(); err != nil {
+        return ErrFailedToUploadFile
+    }
+
+    return nil
+}
+
+// Rename moves the remote file replacing an existing one
+func Rename(ctx context.Context, oldPath string, newPath string) error {
+    connMutex.Lock()
+    defer connMutex.Unlock()
+
+    if conn == nil {
+        return ErrNotConnected
+    }
+
+    client := fsClient()
+    if client == nil {
+        return ErrNotConnected
+    }
+
+    if err := client.PosixRename(oldPath, newPath); err != nil {
+        return ErrFailedToUploadFile
+    }
+
+    return nil
+}
+
+// Remove deletes the remote file
+func Remove(ctx context.Context, remoteFilePath string) error {
+    connMutex.Lock()
+    defer connMutex.Unlock()
+
+    if conn == nil {
+        return ErrNotConnected
+    }
+
+    client := fsClient()
+    if client == nil {
+        return ErrNotConnected
+    }
+
+    if err := client.Remove(remoteFilePath); err != nil {
+        return ErrFailedToUploadFile
+    }
+
+    return nil
+}
+
+// RemoveEmptyDirectory deletes the remote directory if it has no entries
+func RemoveEmptyDirectory(ctx context.Context, remoteDir string) error {
+    connMutex.Lock()
+    defer connMutex.Unlock()
+
+    if conn == nil {
+        return ErrNotConnected
+    }
+
+    client := fsClient()
+    if client == nil {
+        return ErrNotConnected
+    }
+
+    if err := client.RemoveDirectory(remoteDir); err != nil {
+        return ErrFailedToUploadFile
+    }
+
+    return nil
+}
+
+// DownloadStream opens the remote file and passes it to read.
+// Connection is locked while read is running, so read should not call functions of this package.
+func DownloadStream(ctx context.Context, srcPath string, read func(r io.Reader) error) error {
+    connMutex.Lock()
+    defer connMutex.Unlock()
+
+    if conn == nil {
+        return ErrNotConnected
+    }
+
+    client := fsClient()
+    if client == nil {
+        return ErrNotConnected
+    }
+
+    src, err := client.Open(srcPath)
+    if err != nil {
+        return ErrFailedToOpenSource
+    }
+    defer src.Close()
+
+    return read(src)
+}
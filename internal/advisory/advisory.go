@@ -0,0 +1,140 @@
+package advisory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Elementary1092/pm/internal/version"
+)
+
+type Severity int
+
+const (
+	SeverityLow Severity = iota
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"low", "medium", "high", "critical"}
+
+var (
+	ErrFailedToReadDatabase = errors.New("failed to read advisory database")
+	ErrInvalidDatabase      = errors.New("invalid advisory database")
+	ErrUnknownSeverity      = errors.New("unknown severity")
+)
+
+func ParseSeverity(s string) (Severity, error) {
+	for i, name := range severityNames {
+		if strings.EqualFold(s, name) {
+			return Severity(i), nil
+		}
+	}
+
+	return SeverityLow, fmt.Errorf("%w: %s", ErrUnknownSeverity, s)
+}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	severity, err := ParseSeverity(name)
+	if err != nil {
+		return err
+	}
+	*s = severity
+
+	return nil
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Advisory describes vulnerable versions of a package.
+// A version is affected if it satisfies any of Affected constraints.
+// A constraint could combine several version constraints with commas, e.g. ">=1.0,<1.4".
+// Versions are compared with <=, >=, < or > or matched exactly without an operator.
+type Advisory struct {
+	ID          string   `json:"id"`
+	Package     string   `json:"package"`
+	Affected    []string `json:"affected"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+}
+
+// Database is a list of advisories maintained locally
+type Database struct {
+	Advisories []Advisory `json:"advisories"`
+}
+
+func Load(path string) (*Database, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, ErrFailedToReadDatabase
+	}
+
+	var db Database
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+
+	for _, adv := range db.Advisories {
+		if adv.Package == "" || len(adv.Affected) == 0 {
+			return nil, fmt.Errorf("%w: advisory '%s' has no package or affected versions", ErrInvalidDatabase, adv.ID)
+		}
+
+		// a constraint which is not understood would silently match nothing
+		for _, constraint := range adv.Affected {
+			for _, part := range strings.Split(constraint, ",") {
+				if !version.IsValidConstraint(strings.TrimSpace(part)) {
+					return nil, fmt.Errorf("%w: advisory '%s' has unsupported constraint '%s'", ErrInvalidDatabase, adv.ID, constraint)
+				}
+			}
+		}
+	}
+
+	return &db, nil
+}
+
+// Match returns advisories affecting the given version of a package
+func (db *Database) Match(name string, ver string) []Advisory {
+	matched := make([]Advisory, 0)
+	for _, adv := range db.Advisories {
+		if adv.Package == name && adv.affects(ver) {
+			matched = append(matched, adv)
+		}
+	}
+
+	return matched
+}
+
+func (a *Advisory) affects(ver string) bool {
+	for _, constraint := range a.Affected {
+		if satisfiesAll(ver, constraint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func satisfiesAll(ver string, constraint string) bool {
+	for _, part := range strings.Split(constraint, ",") {
+		ok, err := version.Satisfies(ver, strings.TrimSpace(part))
+		if err != nil || !ok {
+			return false
+		}
+	}
+
+	return true
+}
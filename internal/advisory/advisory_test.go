@@ -0,0 +1,95 @@
+package advisory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const database = `{
+ "advisories": [
+  {"id": "PM-1", "package": "packet-3", "affected": [">=1.0,<=1.4", "2.0"], "severity": "high", "description": "remote code execution"},
+  {"id": "PM-2", "package": "packet-3", "affected": ["<=2.0"], "severity": "low", "description": "verbose logs"}
+ ]
+}`
+
+func loadDatabase(t *testing.T, data string) (*Database, error) {
+	path := filepath.Join(t.TempDir(), "advisories.json")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal("Failed to create advisory database:", err)
+	}
+
+	return Load(path)
+}
+
+func TestMatch(t *testing.T) {
+	db, err := loadDatabase(t, database)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if matched := db.Match("packet-3", "1.2"); len(matched) != 2 || matched[0].Severity != SeverityHigh {
+		t.Fatalf("Invalid advisories of 1.2: %+v", matched)
+	}
+
+	if matched := db.Match("packet-3", "1.5"); len(matched) != 1 || matched[0].ID != "PM-2" {
+		t.Fatalf("Invalid advisories of 1.5: %+v", matched)
+	}
+
+	if matched := db.Match("packet-3", "2.1"); len(matched) != 0 {
+		t.Fatalf("Invalid advisories of 2.1: %+v", matched)
+	}
+
+	if matched := db.Match("packet-1", "1.2"); len(matched) != 0 {
+		t.Fatalf("Invalid advisories of another package: %+v", matched)
+	}
+}
+
+func TestMatch_StrictRanges(t *testing.T) {
+	data := `{"advisories": [{"id": "PM-3", "package": "a", "affected": [">1.0,<1.4"], "severity": "medium"}]}`
+	db, err := loadDatabase(t, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ver, affected := range map[string]bool{"1.0": false, "1.1": true, "1.3": true, "1.4": false} {
+		if matched := db.Match("a", ver); (len(matched) == 1) != affected {
+			t.Fatalf("Invalid advisories of %s: %+v", ver, matched)
+		}
+	}
+}
+
+func TestLoad_UnsupportedConstraint(t *testing.T) {
+	data := `{"advisories": [{"id": "PM-1", "package": "a", "affected": [">=1.0,~1.4"], "severity": "low"}]}`
+
+	if _, err := loadDatabase(t, data); !errors.Is(err, ErrInvalidDatabase) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidDatabase, err)
+	}
+}
+
+func TestLoad_UnknownSeverity(t *testing.T) {
+	data := `{"advisories": [{"id": "PM-1", "package": "a", "affected": ["1.0"], "severity": "urgent"}]}`
+
+	if _, err := loadDatabase(t, data); !errors.Is(err, ErrInvalidDatabase) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidDatabase, err)
+	}
+}
+
+func TestLoad_NoAffectedVersions(t *testing.T) {
+	data := `{"advisories": [{"id": "PM-1", "package": "a", "severity": "low"}]}`
+
+	if _, err := loadDatabase(t, data); !errors.Is(err, ErrInvalidDatabase) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidDatabase, err)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	if s, err := ParseSeverity("Critical"); err != nil || s != SeverityCritical {
+		t.Fatalf("Invalid severity: %v, %v", s, err)
+	}
+
+	if _, err := ParseSeverity("urgent"); !errors.Is(err, ErrUnknownSeverity) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnknownSeverity, err)
+	}
+}
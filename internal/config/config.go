@@ -0,0 +1,96 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// EnvConfigPath overrides location of the configuration file
+	EnvConfigPath = "PM_CONFIG"
+)
+
+var (
+	ErrInvalidConfigFormat = errors.New("invalid configuration file format")
+	ErrFailedToReadConfig  = errors.New("failed to read configuration file")
+)
+
+// Config holds local settings of the package manager.
+// Relative paths are resolved against the directory of the configuration file.
+type Config struct {
+	// SigningKey is a PEM encoded PKCS #8 Ed25519 private key used to sign published packages
+	SigningKey string `json:"signing_key,omitempty"`
+	// SigningKeyDeclared reports that SigningKey is set in the configuration file instead of the default one
+	SigningKeyDeclared bool `json:"-"`
+	// TrustedKeys is a file with PEM encoded public keys of trusted publishers
+	TrustedKeys string `json:"trusted_keys,omitempty"`
+	// Advisories is a JSON file with known vulnerable versions of packages
+	Advisories string `json:"advisories,omitempty"`
+	// ExtractLimits override default limits checked while extracting archives
+	ExtractLimits ExtractLimits `json:"extract_limits,omitempty"`
+}
+
+// ExtractLimits are limits of extracted archives. Zero value means default limit.
+type ExtractLimits struct {
+	MaxTotalSize        int64   `json:"max_total_size,omitempty"`
+	MaxFiles            int     `json:"max_files,omitempty"`
+	MaxCompressionRatio float64 `json:"max_compression_ratio,omitempty"`
+}
+
+// Dir returns directory with the default configuration file and keys
+func Dir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".", ".pm")
+	}
+
+	return filepath.Join(dir, "pm")
+}
+
+func Path() string {
+	if path := os.Getenv(EnvConfigPath); path != "" {
+		return path
+	}
+
+	return filepath.Join(Dir(), "config.json")
+}
+
+// Load reads configuration file. Missing file results in the default configuration.
+func Load() (*Config, error) {
+	path := Path()
+	cfg := &Config{}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrFailedToReadConfig
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, ErrInvalidConfigFormat
+		}
+	}
+
+	base := filepath.Dir(path)
+	cfg.SigningKeyDeclared = cfg.SigningKey != ""
+	cfg.SigningKey = resolvePath(base, cfg.SigningKey, "signing.pem")
+	cfg.TrustedKeys = resolvePath(base, cfg.TrustedKeys, "trusted_keys.pem")
+	cfg.Advisories = resolvePath(base, cfg.Advisories, "advisories.json")
+
+	return cfg, nil
+}
+
+func resolvePath(base string, path string, defaultName string) string {
+	if path == "" {
+		return filepath.Join(base, defaultName)
+	}
+
+	if filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(base, path)
+}
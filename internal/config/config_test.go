@@ -0,0 +1,71 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad_Missing(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv(EnvConfigPath, filepath.Join(tmp, "config.json"))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.SigningKey != filepath.Join(tmp, "signing.pem") || cfg.SigningKeyDeclared {
+		t.Fatalf("Invalid default signing key path: '%s'", cfg.SigningKey)
+	}
+
+	if cfg.TrustedKeys != filepath.Join(tmp, "trusted_keys.pem") {
+		t.Fatalf("Invalid default trusted keys path: '%s'", cfg.TrustedKeys)
+	}
+
+	if cfg.Advisories != filepath.Join(tmp, "advisories.json") {
+		t.Fatalf("Invalid default advisories path: '%s'", cfg.Advisories)
+	}
+}
+
+func TestLoad_RelativePaths(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "config.json")
+	t.Setenv(EnvConfigPath, path)
+
+	data := `{"signing_key": "keys/publisher.pem", "trusted_keys": "/etc/pm/trusted.pem", "extract_limits": {"max_files": 10}}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal("Failed to create config file:", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.SigningKey != filepath.Join(tmp, "keys", "publisher.pem") || !cfg.SigningKeyDeclared {
+		t.Fatalf("Invalid signing key path: '%s'", cfg.SigningKey)
+	}
+
+	if cfg.TrustedKeys != "/etc/pm/trusted.pem" {
+		t.Fatalf("Invalid trusted keys path: '%s'", cfg.TrustedKeys)
+	}
+
+	if cfg.ExtractLimits.MaxFiles != 10 {
+		t.Fatalf("Invalid extract limits: %+v", cfg.ExtractLimits)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv(EnvConfigPath, path)
+
+	if err := os.WriteFile(path, []byte("signing_key"), 0644); err != nil {
+		t.Fatal("Failed to create config file:", err)
+	}
+
+	if _, err := Load(); !errors.Is(err, ErrInvalidConfigFormat) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidConfigFormat, err)
+	}
+}
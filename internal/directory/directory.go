@@ -32,8 +32,9 @@ func MakeArchivePathName(at string, packet string, version string) string {
 	return filepath.Join(at, packet, version, packet)
 }
 
+// MakeRemoteArchiveName expects archiveName with an extension of the archive format
 func MakeRemoteArchiveName(packet string, version string, archiveName string) string {
-	return filepath.Join(".", packet, version, archiveName+".zip")
+	return filepath.Join(".", packet, version, archiveName)
 }
 
 func MakeLatestArchiveLink(packet string) string {
@@ -48,7 +49,62 @@ func MakeRemoteMetadataName(packet string, version string) string {
 	return filepath.Join(".", "meta", packet, version, "meta")
 }
 
+// MakeRemoteManifestName returns location of the manifest of all files of a published version
+func MakeRemoteManifestName(packet string, version string) string {
+	return filepath.Join(".", "meta", packet, version, "manifest")
+}
+
+// MakeRemoteDeltaName returns location of the delta archive which updates version from to version.
+// extension is an extension of the archive format with a leading dot.
+func MakeRemoteDeltaName(packet string, version string, from string, extension string) string {
+	return filepath.Join(".", packet, version, packet+".delta-"+from+extension)
+}
+
+// MakePackedDirectoryPath returns directory to which a package is packed without uploading
+func MakePackedDirectoryPath(at string, packet string, version string) string {
+	return filepath.Join(at, packet+"-"+version)
+}
+
+func MakeRemoteAuditLogName(packet string) string {
+	return filepath.Join(".", "meta", packet, "audit.log")
+}
+
 func MakeLatestMetadataLink(packet string) string {
 	return filepath.Join(".", "meta", packet, "latest")
 }
 
+
+func MakeRemotePacketDirectory(packet string) string {
+	return filepath.Join(".", packet)
+}
+
+// MakeInstalledStateDirectory returns directory which keeps information about packages installed to root
+func MakeInstalledStateDirectory(root string) string {
+	return filepath.Join(root, ".pm")
+}
+
+func MakeInstalledPacketStateDirectory(root string, packet string) string {
+	return filepath.Join(MakeInstalledStateDirectory(root), packet)
+}
+
+func MakeInstalledRecordPathName(root string, packet string) string {
+	return filepath.Join(MakeInstalledPacketStateDirectory(root, packet), "installed.json")
+}
+
+func MakeRemoteSignatureName(packet string, version string, archiveName string) string {
+	return MakeRemoteArchiveName(packet, version, archiveName) + ".sig"
+}
+
+func MakeInstalledManifestPathName(root string, packet string) string {
+	return filepath.Join(MakeInstalledPacketStateDirectory(root, packet), "manifest.json")
+}
+
+// MakeInstalledStagingDirectory returns directory to which a package is extracted before it is verified
+func MakeInstalledStagingDirectory(root string, packet string) string {
+	return filepath.Join(MakeInstalledPacketStateDirectory(root, packet), "staging")
+}
+
+// MakeInstalledPreviousDirectory returns directory which keeps previous version of a package while it is replaced
+func MakeInstalledPreviousDirectory(root string, packet string) string {
+	return filepath.Join(MakeInstalledPacketStateDirectory(root, packet), "previous")
+}
@@ -0,0 +1,91 @@
+package installed
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/Elementary1092/pm/internal/directory"
+)
+
+var (
+	ErrNotInstalled        = errors.New("package is not installed")
+	ErrInvalidRecordFormat = errors.New("invalid installed package record format")
+	ErrFailedToSaveRecord  = errors.New("failed to save installed package record")
+	ErrFailedToReadRecord  = errors.New("failed to read installed package record")
+)
+
+// Record describes a package installed by update command.
+// Records are kept in the state directory of the installation root.
+type Record struct {
+	Name    string `json:"name"`
+	Version string `json:"ver"`
+	Group   string `json:"group,omitempty"`
+}
+
+func Save(root string, record *Record) error {
+	if err := os.MkdirAll(directory.MakeInstalledPacketStateDirectory(root, record.Name), os.ModePerm); err != nil {
+		return ErrFailedToSaveRecord
+	}
+
+	data, err := json.MarshalIndent(record, "", " ")
+	if err != nil {
+		return ErrFailedToSaveRecord
+	}
+
+	if err := os.WriteFile(directory.MakeInstalledRecordPathName(root, record.Name), data, 0644); err != nil {
+		return ErrFailedToSaveRecord
+	}
+
+	return nil
+}
+
+func Load(root string, name string) (*Record, error) {
+	data, err := os.ReadFile(directory.MakeInstalledRecordPathName(root, name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotInstalled
+		}
+
+		return nil, ErrFailedToReadRecord
+	}
+
+	var record Record
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, ErrInvalidRecordFormat
+	}
+
+	return &record, nil
+}
+
+// LoadAll returns records of every package installed to root.
+// State directories without a record are skipped: a failed first install leaves only its staging directory.
+func LoadAll(root string) ([]*Record, error) {
+	entries, err := os.ReadDir(directory.MakeInstalledStateDirectory(root))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, ErrFailedToReadRecord
+	}
+
+	records := make([]*Record, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		record, err := Load(root, entry.Name())
+		if errors.Is(err, ErrNotInstalled) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,82 @@
+package installed
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/Elementary1092/pm/internal/directory"
+)
+
+func TestLoad_NotInstalled(t *testing.T) {
+	if _, err := Load(t.TempDir(), "packet-1"); !errors.Is(err, ErrNotInstalled) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrNotInstalled, err)
+	}
+}
+
+func TestSave_Load(t *testing.T) {
+	tmp := t.TempDir()
+
+	if err := Save(tmp, &Record{Name: "packet-1", Version: "1.10"}); err != nil {
+		t.Fatal("Failed to save record:", err)
+	}
+
+	record, err := Load(tmp, "packet-1")
+	if err != nil {
+		t.Fatal("Failed to load record:", err)
+	}
+
+	if record.Name != "packet-1" || record.Version != "1.10" {
+		t.Fatalf("Invalid record: name='%s'; ver='%s'", record.Name, record.Version)
+	}
+}
+
+func TestLoadAll_NoState(t *testing.T) {
+	records, err := LoadAll(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 0 {
+		t.Fatalf("Invalid number of records: expected=0; got=%d", len(records))
+	}
+}
+
+func TestLoadAll(t *testing.T) {
+	tmp := t.TempDir()
+
+	for _, name := range []string{"packet-1", "packet-2"} {
+		if err := Save(tmp, &Record{Name: name, Version: "1.0"}); err != nil {
+			t.Fatal("Failed to save record:", err)
+		}
+	}
+
+	records, err := LoadAll(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("Invalid number of records: expected=2; got=%d", len(records))
+	}
+}
+
+func TestLoadAll_FailedInstall(t *testing.T) {
+	tmp := t.TempDir()
+	if err := Save(tmp, &Record{Name: "packet-1", Version: "1.0"}); err != nil {
+		t.Fatal("Failed to save record:", err)
+	}
+	// the first install of packet-2 failed before its record was saved
+	if err := os.MkdirAll(directory.MakeInstalledStagingDirectory(tmp, "packet-2"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := LoadAll(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 || records[0].Name != "packet-1" {
+		t.Fatalf("Invalid records: %+v", records)
+	}
+}
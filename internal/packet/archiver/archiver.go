@@ -2,11 +2,21 @@ package archiver
 
 import (
 	"archive/zip"
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+	"github.com/Elementary1092/pm/internal/packet/manifest"
 )
 
 var (
@@ -20,13 +30,109 @@ var (
 	ErrFailedToCreateDirectory      = errors.New("failed to create directory")
 	ErrFailedToCreateFile           = errors.New("failed to create file")
 	ErrFailedToExtractFile          = errors.New("failed to extract file")
+	ErrUnsafePath                   = errors.New("archive entry points outside of the destination")
+	ErrUnsafeSymlink                = errors.New("symbolic link points outside of the destination")
+	ErrTooManyFiles                 = errors.New("too many files in the archive")
+	ErrArchiveTooLarge              = errors.New("uncompressed archive is too large")
+	ErrCompressionRatio             = errors.New("suspicious compression ratio")
+	ErrNoManifest                   = errors.New("archive has no manifest")
 )
 
+// Options choose file attributes which are stored in an archive
+type Options struct {
+	// PreserveMode stores permission bits, otherwise they are restored by extractor defaults
+	PreserveMode bool
+	// PreserveModTime stores modification times
+	PreserveModTime bool
+	// PreserveSymlinks stores symbolic links as links, otherwise files they point to are stored
+	PreserveSymlinks bool
+	// NormalizeModes stores 0755 for directories and executable files and 0644 for other files
+	// instead of actual permission bits, so archives do not depend on umask
+	NormalizeModes bool
+	// ModTime replaces modification time of every entry if it is not zero
+	ModTime time.Time
+	// CompressionLevel from 1 (fastest) to MaxCompressionLevel, default level of the format is used if it is 0
+	CompressionLevel int
+	// Workers is the number of goroutines compressing files, runtime.NumCPU() is used if it is 0.
+	// Archives do not depend on the number of workers.
+	Workers int
+	// PathsInArchive maps file names to slash separated paths in the archive,
+	// files which are not listed are stored relative to the root
+	PathsInArchive map[string]string
+	// FollowedLinks are symbolic links which are stored as files or directories they point to
+	// even if PreserveSymlinks is set
+	FollowedLinks map[string]bool
+}
+
+func (o Options) workers() int {
+	if o.Workers <= 0 {
+		return runtime.NumCPU()
+	}
+
+	return o.Workers
+}
+
+// PathInArchive returns the path of fileName in the archive with the OS separator
+func (o Options) PathInArchive(root string, fileName string) (string, error) {
+	if pathInArchive, ok := o.PathsInArchive[fileName]; ok {
+		return filepath.FromSlash(pathInArchive), nil
+	}
+
+	pathInArchive, err := filepath.Rel(root, fileName)
+	if err != nil {
+		return "", ErrFailedToArchiveFile
+	}
+
+	return pathInArchive, nil
+}
+
+// DefaultOptions preserve every supported attribute
+var DefaultOptions = Options{
+	PreserveMode:     true,
+	PreserveModTime:  true,
+	PreserveSymlinks: true,
+}
+
+// EnvSourceDateEpoch is a Unix timestamp used as modification time of archived files
+// (see https://reproducible-builds.org/specs/source-date-epoch/)
+const EnvSourceDateEpoch = "SOURCE_DATE_EPOCH"
+
+// NormalizedModTime is used by reproducible archives if SOURCE_DATE_EPOCH is not set.
+// It is the earliest time which could be stored in zip archives.
+var NormalizedModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	ErrInvalidSourceDateEpoch = errors.New("invalid " + EnvSourceDateEpoch)
+)
+
+// SourceDateEpoch returns time set by SOURCE_DATE_EPOCH.
+// false is returned if the variable is not set.
+func SourceDateEpoch() (time.Time, bool, error) {
+	value := os.Getenv(EnvSourceDateEpoch)
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, false, fmt.Errorf("%w: %s", ErrInvalidSourceDateEpoch, value)
+	}
+
+	return time.Unix(seconds, 0).UTC(), true, nil
+}
+
 // Archive creates an archive and writes contents of all files given in fileNames to it.
 // root should be a common prefix of all files listed in fileNames.
+// Format of the archive is chosen by extension of archieveName (zip if it is unknown).
 // Archive path with name is returned.
-// Archive file extenstion is appended by a function.
 func Archive(root string, archieveName string, fileNames []string) (string, error) {
+	return ArchiveWithOptions(root, archieveName, fileNames, DefaultOptions)
+}
+
+// ArchiveWithOptions is Archive which stores file attributes chosen by opts.
+// Directories listed in fileNames are stored as empty directories.
+// Entries are sorted by path, so the same files with the same attributes give identical archives.
+func ArchiveWithOptions(root string, archieveName string, fileNames []string, opts Options) (string, error) {
 	root = strings.TrimSpace(root)
 	archieveName = strings.TrimSpace(archieveName)
 
@@ -49,39 +155,332 @@ func Archive(root string, archieveName string, fileNames []string) (string, erro
 	}
 	defer archive.Close()
 
-	zipWriter := zip.NewWriter(archive)
-	defer zipWriter.Close()
+	if _, err := ArchiveTo(archive, FormatOf(archieveName), root, fileNames, opts); err != nil {
+		return "", err
+	}
+
+	if err := archive.Close(); err != nil {
+		return "", ErrFailedToCreateArchieve
+	}
+
+	return archieveName, nil
+}
+
+// ArchiveTo writes an archive of the given format to w.
+// It is ArchiveWithOptions which does not need a file, so the archive could be streamed.
+// Manifest embedded into the archive is returned.
+func ArchiveTo(w io.Writer, format Format, root string, fileNames []string, opts Options) (*manifest.Manifest, error) {
+	root = strings.TrimSpace(root)
+	if root == "" {
+		return nil, ErrInvalidRootPath
+	}
+
+	archiveWriter, err := newArchiveWriter(w, format, opts.CompressionLevel, opts.workers())
+	if err != nil {
+		return nil, err
+	}
+
+	defer archiveWriter.Close()
+	files, err := describeFiles(root, fileNames, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	compressor := startParallelCompressor(archiveWriter, files, opts)
+	defer compressor.stop()
+
+	packManifest := &manifest.Manifest{
+		ModesPreserved: opts.PreserveMode,
+	}
+	for i, file := range files {
+		if file.info.IsDir() {
+			if _, err := archiveWriter.Create(makeHeader(file.pathInArchive, file.info, opts)); err != nil {
+				return nil, ErrFailedToCreateCompressedFile
+			}
+			continue
+		}
+
+		if file.info.Mode()&fs.ModeSymlink != 0 && opts.PreserveSymlinks {
+			entry, err := archiveSymlink(archiveWriter, file.name, file.pathInArchive, file.info, opts)
+			if err != nil {
+				return nil, err
+			}
+			packManifest.Add(entry)
+			continue
+		}
+
+		entry, compressed, err := compressor.take(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if !compressed {
+			entry, err = archiveFile(archiveWriter, file, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		packManifest.Add(entry)
+	}
+
+	if err := writeManifest(archiveWriter, packManifest); err != nil {
+		return nil, err
+	}
+
+	if err := archiveWriter.Close(); err != nil {
+		return nil, ErrFailedToCreateArchieve
+	}
+
+	return packManifest, nil
+}
+
+// archivedFile is a file listed for archiving
+type archivedFile struct {
+	name          string
+	pathInArchive string
+	// info describes the file itself, not a file a symbolic link points to
+	info fs.FileInfo
+}
+
+// describeFiles returns files ordered by their paths in the archive
+func describeFiles(root string, fileNames []string, opts Options) ([]archivedFile, error) {
+	sortedNames, err := sortByPathInArchive(root, fileNames, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]archivedFile, 0, len(sortedNames))
+	for _, fileName := range sortedNames {
+		pathInArchive, err := opts.PathInArchive(root, fileName)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.ToSlash(pathInArchive) == manifest.Name {
+			return nil, fmt.Errorf("%w: %s is reserved for the package manifest", ErrInvalidFileName, manifest.Name)
+		}
+
+		info, err := os.Lstat(fileName)
+		if err == nil && opts.FollowedLinks[fileName] {
+			info, err = os.Stat(fileName)
+		}
+		if err != nil {
+			return nil, ErrFailedToOpenFile
+		}
+
+		files = append(files, archivedFile{
+			name:          fileName,
+			pathInArchive: pathInArchive,
+			info:          info,
+		})
+	}
+
+	return files, nil
+}
+
+// archiveFile writes a regular file or a file a symbolic link points to.
+// The file is closed before it returns, so the number of open files does not grow with the archive.
+func archiveFile(archiveWriter archiveWriter, file archivedFile, opts Options) (manifest.Entry, error) {
+	f, err := os.Open(file.name)
+	if err != nil {
+		return manifest.Entry{}, ErrFailedToOpenFile
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return manifest.Entry{}, ErrFailedToOpenFile
+	}
+
+	compressed, err := archiveWriter.Create(makeHeader(file.pathInArchive, info, opts))
+	if err != nil {
+		return manifest.Entry{}, ErrFailedToCreateCompressedFile
+	}
+
+	digest := checksum.NewWriter()
+	if _, err := io.Copy(io.MultiWriter(compressed, digest), f); err != nil {
+		return manifest.Entry{}, ErrFailedToCreateCompressedFile
+	}
+
+	return manifest.Entry{
+		Path:   filepath.ToSlash(file.pathInArchive),
+		Size:   digest.Digest().Size,
+		Mode:   storedMode(info.Mode(), opts),
+		SHA256: digest.Digest().Sum,
+	}, nil
+}
+
+// sortByPathInArchive returns trimmed copy of fileNames ordered by their paths in the archive
+func sortByPathInArchive(root string, fileNames []string, opts Options) ([]string, error) {
+	sorted := make([]string, 0, len(fileNames))
+	paths := make(map[string]string, len(fileNames))
 	for _, fileName := range fileNames {
 		fileName = strings.TrimSpace(fileName)
 		if fileName == "" {
-			return "", ErrInvalidFileName
+			return nil, ErrInvalidFileName
 		}
 
-		f, err := os.Open(fileName)
+		pathInArchive, err := opts.PathInArchive(root, fileName)
 		if err != nil {
-			return "", ErrFailedToOpenFile
+			return nil, err
 		}
-		defer f.Close()
 
-        pathInArchive, err := filepath.Rel(root, fileName)
-        if err != nil {
-            return "", ErrFailedToArchiveFile
-        }
-		compressed, err := zipWriter.Create(pathInArchive)
+		paths[fileName] = filepath.ToSlash(pathInArchive)
+		sorted = append(sorted, fileName)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return paths[sorted[i]] < paths[sorted[j]]
+	})
+
+	return sorted, nil
+}
+
+func makeHeader(pathInArchive string, info fs.FileInfo, opts Options) header {
+	h := header{
+		Name:    filepath.ToSlash(pathInArchive),
+		Mode:    storedMode(info.Mode(), opts),
+		ModeSet: opts.PreserveMode,
+	}
+
+	if info.Mode().IsRegular() {
+		h.Size = info.Size()
+	}
+
+	if opts.PreserveModTime {
+		h.ModTime = info.ModTime()
+		if !opts.ModTime.IsZero() {
+			h.ModTime = opts.ModTime
+		}
+	}
+
+	return h
+}
+
+// storedMode applies NormalizeModes option
+func storedMode(mode fs.FileMode, opts Options) fs.FileMode {
+	if !opts.NormalizeModes {
+		return mode
+	}
+
+	if mode.IsRegular() && mode&0100 != 0 {
+		return 0755
+	}
+
+	return mode.Type() | defaultPerm(mode)
+}
+
+// archiveSymlink stores a symbolic link as a link.
+// Manifest entry of a link describes its target path.
+func archiveSymlink(archiveWriter archiveWriter, fileName string, pathInArchive string, info fs.FileInfo, opts Options) (manifest.Entry, error) {
+	target, err := os.Readlink(fileName)
+	if err != nil {
+		return manifest.Entry{}, ErrFailedToOpenFile
+	}
+
+	h := makeHeader(pathInArchive, info, opts)
+	h.Linkname = target
+	if _, err := archiveWriter.Create(h); err != nil {
+		return manifest.Entry{}, ErrFailedToCreateCompressedFile
+	}
+
+	digest, err := checksum.Compute(strings.NewReader(target))
+	if err != nil {
+		return manifest.Entry{}, ErrFailedToArchiveFile
+	}
+
+	return manifest.Entry{
+		Path:   h.Name,
+		Size:   digest.Size,
+		Mode:   h.Mode,
+		SHA256: digest.Sum,
+	}, nil
+}
+
+// writeManifest stores manifest as the last entry of the archive
+func writeManifest(archiveWriter archiveWriter, packManifest *manifest.Manifest) error {
+	packManifest.Sort()
+	var encoded bytes.Buffer
+	if err := packManifest.Encode(&encoded); err != nil {
+		return ErrFailedToCreateCompressedFile
+	}
+
+	compressed, err := archiveWriter.Create(header{
+		Name: manifest.Name,
+		Size: int64(encoded.Len()),
+		Mode: 0644,
+	})
+	if err != nil {
+		return ErrFailedToCreateCompressedFile
+	}
+
+	if _, err := compressed.Write(encoded.Bytes()); err != nil {
+		return ErrFailedToCreateCompressedFile
+	}
+
+	return nil
+}
+
+// ReadManifest returns manifest embedded into the archive
+func ReadManifest(archiveFullName string) (*manifest.Manifest, error) {
+	reader, err := openArchiveReader(archiveFullName)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	for {
+		e, err := reader.Next()
+		if err == io.EOF {
+			return nil, ErrNoManifest
+		}
 		if err != nil {
-			return "", ErrFailedToCreateCompressedFile
+			return nil, err
 		}
 
-		if _, err := io.Copy(compressed, f); err != nil {
-			return "", ErrFailedToCreateCompressedFile
+		if e.Name == manifest.Name {
+			return readManifestEntry(e)
 		}
 	}
+}
 
-	return archieveName, nil
+func readManifestEntry(e *entry) (*manifest.Manifest, error) {
+	compressed, err := e.Open()
+	if err != nil {
+		return nil, ErrFailedToOpenFile
+	}
+	defer compressed.Close()
+
+	return manifest.Decode(compressed)
+}
+
+// Limits protect from archives which would exhaust disk space when extracted.
+// Zero value of a field disables the corresponding check.
+type Limits struct {
+	// MaxTotalSize is the maximum total uncompressed size of all files in bytes
+	MaxTotalSize int64
+	// MaxFiles is the maximum number of entries in an archive
+	MaxFiles int
+	// MaxCompressionRatio is the maximum ratio of uncompressed to compressed size of a single file
+	MaxCompressionRatio float64
+}
+
+// DefaultLimits are used by ExtractFrom
+var DefaultLimits = Limits{
+	MaxTotalSize:        16 << 30,
+	MaxFiles:            1_000_000,
+	MaxCompressionRatio: 200,
 }
 
 // ExtractFrom opens archiveFullName file and writes its contents to the extractToPath
 func ExtractFrom(archiveFullName string, extractToPath string) error {
+	return ExtractFromWithLimits(archiveFullName, extractToPath, DefaultLimits)
+}
+
+// ExtractFromWithLimits is ExtractFrom which checks archive against given limits.
+// Entries with absolute paths, entries escaping extractToPath and symbolic links
+// pointing outside of extractToPath are rejected.
+func ExtractFromWithLimits(archiveFullName string, extractToPath string, limits Limits) error {
 	archiveFullName = strings.TrimSpace(archiveFullName)
 	extractToPath = strings.TrimSpace(extractToPath)
 
@@ -93,42 +492,336 @@ func ExtractFrom(archiveFullName string, extractToPath string) error {
 		return ErrInvalidFileName
 	}
 
-	zipReader, err := zip.OpenReader(archiveFullName)
+	reader, err := openArchiveReader(archiveFullName)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := reader.Check(limits); err != nil {
+		return err
+	}
+
+	archiveInfo, err := os.Stat(archiveFullName)
 	if err != nil {
 		return ErrFailedToOpenFile
 	}
-	defer zipReader.Close()
 
-	for _, f := range zipReader.File {
-		// assuming that filepaths from a zip file are correct
-		filePath := filepath.Join(extractToPath, f.Name)
-		if f.FileInfo().IsDir() {
+	_, err = extract(reader, func() int64 { return archiveInfo.Size() }, extractToPath, limits)
+	return err
+}
+
+// ExtractStream extracts an archive of a streamable format while it is being read from r.
+// Limits are checked the same way as by ExtractFromWithLimits.
+// Manifest of the archive is returned, it is nil if the archive has no manifest.
+func ExtractStream(r io.Reader, format Format, extractToPath string, limits Limits) (*manifest.Manifest, error) {
+	extractToPath = strings.TrimSpace(extractToPath)
+	if extractToPath == "" {
+		return nil, ErrInvalidFileName
+	}
+
+	counter := &countingReader{r: r}
+	reader, err := newTarArchiveReader(counter, format)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return extract(reader, func() int64 { return counter.n }, extractToPath, limits)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// extract writes entries of reader to extractToPath.
+// compressedSize returns number of compressed bytes read so far: size of a compressed stream
+// is known only after it is read completely, so the ratio of the whole archive is checked while extracting.
+func extract(reader archiveReader, compressedSize func() int64, extractToPath string, limits Limits) (*manifest.Manifest, error) {
+	extractToPath, err := filepath.Abs(extractToPath)
+	if err != nil {
+		return nil, ErrInvalidFileName
+	}
+
+	var packManifest *manifest.Manifest
+	// declared sizes could be forged, so the actual number of written bytes is limited too
+	var written int64
+	entries := 0
+	// attributes of directories are restored after their content is written
+	dirs := make([]extractedDir, 0)
+	for {
+		e, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entries++
+		if limits.MaxFiles != 0 && entries > limits.MaxFiles {
+			return nil, fmt.Errorf("%w: at most %d entries are allowed", ErrTooManyFiles, limits.MaxFiles)
+		}
+
+		// manifest is not a part of the package content
+		if e.Name == manifest.Name {
+			if packManifest, err = readManifestEntry(e); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		filePath, err := safeJoin(extractToPath, e.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Mode.IsDir() {
+			if err := checkParents(extractToPath, filePath); err != nil {
+				return nil, err
+			}
 			if err := os.MkdirAll(filePath, os.ModePerm); err != nil {
-				return ErrFailedToCreateDirectory
+				return nil, ErrFailedToCreateDirectory
 			}
+			dirs = append(dirs, extractedDir{path: filePath, entry: *e})
 			continue
 		}
 
+		if err := checkParents(extractToPath, filepath.Dir(filePath)); err != nil {
+			return nil, err
+		}
 		if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
-			return ErrFailedToCreateDirectory
+			return nil, ErrFailedToCreateDirectory
 		}
 
-		file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
-		if err != nil {
-			return ErrFailedToCreateFile
+		if e.Mode&fs.ModeSymlink != 0 {
+			if err := extractSymlink(e, extractToPath, filePath); err != nil {
+				return nil, err
+			}
+			continue
 		}
-		defer file.Close()
 
-		compressedFile, err := f.Open()
+		n, err := extractFile(e, filePath, limits.MaxTotalSize-written, limits.MaxTotalSize != 0)
 		if err != nil {
-			return ErrFailedToOpenFile
+			return nil, err
+		}
+		written += n
+
+		if limits.MaxCompressionRatio != 0 {
+			compressed := compressedSize()
+			if compressed == 0 {
+				compressed = 1
+			}
+			if ratio := float64(written) / float64(compressed); ratio > limits.MaxCompressionRatio {
+				return nil, fmt.Errorf("%w: archive has ratio %.0f, at most %.0f is allowed", ErrCompressionRatio, ratio, limits.MaxCompressionRatio)
+			}
 		}
-		defer compressedFile.Close()
 
-		if _, err := io.Copy(file, compressedFile); err != nil {
+		if err := restoreAttributes(filePath, e); err != nil {
+			return nil, err
+		}
+	}
+
+	// nested directories go after their parents, so they are restored first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := restoreAttributes(dirs[i].path, &dirs[i].entry); err != nil {
+			return nil, err
+		}
+	}
+
+	return packManifest, nil
+}
+
+type extractedDir struct {
+	path  string
+	entry entry
+}
+
+// restoreAttributes sets mode and modification time stored in the archive
+func restoreAttributes(filePath string, e *entry) error {
+	if e.ModeSet {
+		if err := os.Chmod(filePath, e.Mode.Perm()); err != nil {
+			return ErrFailedToExtractFile
+		}
+	}
+
+	if !e.ModTime.IsZero() {
+		if err := os.Chtimes(filePath, e.ModTime, e.ModTime); err != nil {
 			return ErrFailedToExtractFile
 		}
 	}
 
 	return nil
 }
+
+func checkLimits(files []*zip.File, limits Limits) error {
+	if limits.MaxFiles != 0 && len(files) > limits.MaxFiles {
+		return fmt.Errorf("%w: archive has %d entries, at most %d are allowed", ErrTooManyFiles, len(files), limits.MaxFiles)
+	}
+
+	var total uint64
+	for _, f := range files {
+		total += f.UncompressedSize64
+		if limits.MaxTotalSize != 0 && total > uint64(limits.MaxTotalSize) {
+			return fmt.Errorf("%w: uncompressed size exceeds %d bytes", ErrArchiveTooLarge, limits.MaxTotalSize)
+		}
+
+		if limits.MaxCompressionRatio == 0 || f.UncompressedSize64 == 0 {
+			continue
+		}
+
+		compressed := f.CompressedSize64
+		if compressed == 0 {
+			compressed = 1
+		}
+		if ratio := float64(f.UncompressedSize64) / float64(compressed); ratio > limits.MaxCompressionRatio {
+			return fmt.Errorf("%w: %s has ratio %.0f, at most %.0f is allowed", ErrCompressionRatio, f.Name, ratio, limits.MaxCompressionRatio)
+		}
+	}
+
+	return nil
+}
+
+// safeJoin joins archive entry name to root rejecting names which point outside of root
+func safeJoin(root string, name string) (string, error) {
+	if name == "" || filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+
+	filePath := filepath.Join(root, name)
+	if !isWithin(root, filePath) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+
+	return filePath, nil
+}
+
+// checkParents rejects paths going through symbolic links: entries under a link would be written
+// where it points to, so a link extracted earlier could redirect the following entries outside of root.
+// dir and its parents up to root are checked, directories which do not exist yet are created by extract.
+func checkParents(root string, dir string) error {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnsafePath, dir)
+	}
+	if rel == "." {
+		return nil
+	}
+
+	parent := root
+	for _, segment := range strings.Split(rel, string(filepath.Separator)) {
+		parent = filepath.Join(parent, segment)
+		info, err := os.Lstat(parent)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return ErrFailedToCreateDirectory
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s is a symbolic link", ErrUnsafePath, parent)
+		}
+	}
+
+	return nil
+}
+
+// descendsOnly reports whether a relative link target goes up only before it goes down.
+// Targets like "dir/../.." are resolved by the system through dir, which could be a link, so they are refused.
+func descendsOnly(target string) bool {
+	descending := false
+	for _, segment := range strings.Split(filepath.ToSlash(target), "/") {
+		switch segment {
+		case "", ".":
+		case "..":
+			if descending {
+				return false
+			}
+		default:
+			descending = true
+		}
+	}
+
+	return true
+}
+
+func isWithin(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// extractSymlink creates a symbolic link if it points inside of root
+func extractSymlink(e *entry, root string, linkPath string) error {
+	compressed, err := e.Open()
+	if err != nil {
+		return ErrFailedToOpenFile
+	}
+	defer compressed.Close()
+
+	target, err := io.ReadAll(io.LimitReader(compressed, 4096))
+	if err != nil {
+		return ErrFailedToExtractFile
+	}
+
+	linkTo := string(target)
+	if filepath.IsAbs(linkTo) || !descendsOnly(linkTo) || !isWithin(root, filepath.Join(filepath.Dir(linkPath), linkTo)) {
+		return fmt.Errorf("%w: %s -> %s", ErrUnsafeSymlink, e.Name, linkTo)
+	}
+
+	os.Remove(linkPath)
+	if err := os.Symlink(linkTo, linkPath); err != nil {
+		return ErrFailedToCreateFile
+	}
+
+	return nil
+}
+
+// extractFile writes at most limit bytes (if limited) of f to filePath.
+// Number of written bytes is returned.
+func extractFile(e *entry, filePath string, limit int64, limited bool) (int64, error) {
+	// the file replaces a link instead of being written where the link points to
+	if info, err := os.Lstat(filePath); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if err := os.Remove(filePath); err != nil {
+			return 0, ErrFailedToCreateFile
+		}
+	}
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, e.Mode)
+	if err != nil {
+		return 0, ErrFailedToCreateFile
+	}
+	defer file.Close()
+
+	compressedFile, err := e.Open()
+	if err != nil {
+		return 0, ErrFailedToOpenFile
+	}
+	defer compressedFile.Close()
+
+	var src io.Reader = compressedFile
+	if limited {
+		src = io.LimitReader(compressedFile, limit+1)
+	}
+
+	n, err := io.Copy(file, src)
+	if err != nil {
+		return n, ErrFailedToExtractFile
+	}
+
+	if limited && n > limit {
+		return n, fmt.Errorf("%w: limit is exceeded while extracting %s", ErrArchiveTooLarge, e.Name)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,53 @@
+package archiver
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// makeBenchmarkFiles creates count files of the given size with partially compressible content
+func makeBenchmarkFiles(b *testing.B, count int, size int) (string, []string) {
+	root := b.TempDir()
+	random := rand.New(rand.NewSource(1))
+	content := make([]byte, size)
+	names := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		random.Read(content[:size/2])
+		name := filepath.Join(root, fmt.Sprintf("dir%03d", i%100), fmt.Sprintf("file%06d", i))
+		if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(name, content, 0644); err != nil {
+			b.Fatal(err)
+		}
+		names = append(names, name)
+	}
+
+	return root, names
+}
+
+func benchmarkArchiveTo(b *testing.B, count int, size int) {
+	root, names := makeBenchmarkFiles(b, count, size)
+	for _, format := range formats {
+		b.Run(string(format), func(b *testing.B) {
+			b.SetBytes(int64(count) * int64(size))
+			for i := 0; i < b.N; i++ {
+				if _, err := ArchiveTo(io.Discard, format, root, names, DefaultOptions); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkArchiveTo_ManyFiles(b *testing.B) {
+	benchmarkArchiveTo(b, 20000, 4<<10)
+}
+
+func BenchmarkArchiveTo_LargeFiles(b *testing.B) {
+	benchmarkArchiveTo(b, 4, 64<<20)
+}
@@ -1,11 +1,19 @@
 package archiver
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
 	"errors"
+	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"testing/fstest"
+	"time"
 )
 
 func TestArchive_EmptyRoot(t *testing.T) {
@@ -146,3 +154,778 @@ func TestExtractFrom_ExtractFromAchive(t *testing.T) {
     }
 }
 
+
+type zipEntry struct {
+	name string
+	mode os.FileMode
+	body string
+}
+
+func makeZip(t *testing.T, entries []zipEntry) string {
+	archivePath := filepath.Join(t.TempDir(), "archive.zip")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal("Failed to create archive:", err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		if entry.mode != 0 {
+			header.SetMode(entry.mode)
+		}
+
+		compressed, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal("Failed to create archive entry:", err)
+		}
+
+		if _, err := compressed.Write([]byte(entry.body)); err != nil {
+			t.Fatal("Failed to write archive entry:", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal("Failed to close archive:", err)
+	}
+
+	return archivePath
+}
+
+func TestExtractFrom_ZipSlip(t *testing.T) {
+	archivePath := makeZip(t, []zipEntry{{name: "../../.bashrc", body: "evil"}})
+	extractPath := filepath.Join(t.TempDir(), "a", "b")
+
+	if err := ExtractFrom(archivePath, extractPath); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnsafePath, err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(extractPath, "..", "..", ".bashrc")); err == nil {
+		t.Fatal("File was written outside of the destination")
+	}
+}
+
+func TestExtractFrom_AbsolutePath(t *testing.T) {
+	archivePath := makeZip(t, []zipEntry{{name: "/tmp/evil", body: "evil"}})
+
+	if err := ExtractFrom(archivePath, t.TempDir()); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnsafePath, err)
+	}
+}
+
+func TestExtractFrom_SymlinkOutside(t *testing.T) {
+	archivePath := makeZip(t, []zipEntry{{name: "link", mode: os.ModeSymlink | 0777, body: "../../etc"}})
+
+	if err := ExtractFrom(archivePath, t.TempDir()); !errors.Is(err, ErrUnsafeSymlink) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnsafeSymlink, err)
+	}
+}
+
+func TestExtractFrom_SymlinkAbsolute(t *testing.T) {
+	archivePath := makeZip(t, []zipEntry{{name: "link", mode: os.ModeSymlink | 0777, body: "/etc/passwd"}})
+
+	if err := ExtractFrom(archivePath, t.TempDir()); !errors.Is(err, ErrUnsafeSymlink) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnsafeSymlink, err)
+	}
+}
+
+func TestExtractFrom_SymlinkChain(t *testing.T) {
+	archivePath := makeZip(t, []zipEntry{
+		{name: "sub/", mode: os.ModeDir | 0755},
+		{name: "sub/y", mode: os.ModeSymlink | 0777, body: ".."},
+		{name: "sub/z", mode: os.ModeSymlink | 0777, body: "y/../.."},
+		{name: "sub/z/escaped.txt", body: "evil"},
+	})
+	extractPath := filepath.Join(t.TempDir(), "a", "b")
+
+	if err := ExtractFrom(archivePath, extractPath); !errors.Is(err, ErrUnsafeSymlink) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnsafeSymlink, err)
+	}
+
+	if _, err := os.Lstat(filepath.Join(extractPath, "..", "escaped.txt")); err == nil {
+		t.Fatal("File was written outside of the destination")
+	}
+}
+
+func TestExtractFrom_EntryUnderSymlink(t *testing.T) {
+	for _, under := range []zipEntry{{name: "link/file", body: "text"}, {name: "link/dir/", mode: os.ModeDir | 0755}} {
+		name := under.name
+		archivePath := makeZip(t, []zipEntry{
+			{name: "data/", mode: os.ModeDir | 0755},
+			{name: "link", mode: os.ModeSymlink | 0777, body: "data"},
+			under,
+		})
+		extractPath := t.TempDir()
+
+		if err := ExtractFrom(archivePath, extractPath); !errors.Is(err, ErrUnsafePath) {
+			t.Fatalf("Unexpected error of %s: expected='%v'; got='%v'", name, ErrUnsafePath, err)
+		}
+
+		entries, err := os.ReadDir(filepath.Join(extractPath, "data"))
+		if err != nil || len(entries) != 0 {
+			t.Fatalf("Entry %s was written through the link: %v", name, err)
+		}
+	}
+}
+
+func TestExtractFrom_FileReplacesSymlink(t *testing.T) {
+	archivePath := makeZip(t, []zipEntry{
+		{name: "data/file", body: "some text"},
+		{name: "link", mode: os.ModeSymlink | 0777, body: "data/file"},
+		{name: "link", body: "replaced"},
+	})
+	extractPath := t.TempDir()
+
+	if err := ExtractFrom(archivePath, extractPath); err != nil {
+		t.Fatal("Failed to extract files from the archive:", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(extractPath, "data", "file")); err != nil || string(data) != "some text" {
+		t.Fatalf("File was written through the link: '%s', %v", data, err)
+	}
+}
+
+func TestExtractFrom_SymlinkInside(t *testing.T) {
+	archivePath := makeZip(t, []zipEntry{
+		{name: "data/file", body: "some text"},
+		{name: "link", mode: os.ModeSymlink | 0777, body: "data/file"},
+	})
+	extractPath := t.TempDir()
+
+	if err := ExtractFrom(archivePath, extractPath); err != nil {
+		t.Fatal("Failed to extract files from the archive:", err)
+	}
+
+	target, err := os.Readlink(filepath.Join(extractPath, "link"))
+	if err != nil || target != "data/file" {
+		t.Fatalf("Invalid symbolic link: target='%s'; err='%v'", target, err)
+	}
+}
+
+func TestExtractFromWithLimits_TooManyFiles(t *testing.T) {
+	archivePath := makeZip(t, []zipEntry{{name: "a"}, {name: "b"}, {name: "c"}})
+
+	err := ExtractFromWithLimits(archivePath, t.TempDir(), Limits{MaxFiles: 2})
+	if !errors.Is(err, ErrTooManyFiles) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrTooManyFiles, err)
+	}
+}
+
+func TestExtractFromWithLimits_TooLarge(t *testing.T) {
+	archivePath := makeZip(t, []zipEntry{{name: "a", body: "some text"}, {name: "b", body: "some text"}})
+
+	err := ExtractFromWithLimits(archivePath, t.TempDir(), Limits{MaxTotalSize: 10})
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrArchiveTooLarge, err)
+	}
+}
+
+func TestExtractFromWithLimits_CompressionRatio(t *testing.T) {
+	archivePath := makeZip(t, []zipEntry{{name: "bomb", body: strings.Repeat("0", 1<<20)}})
+
+	err := ExtractFromWithLimits(archivePath, t.TempDir(), Limits{MaxCompressionRatio: 100})
+	if !errors.Is(err, ErrCompressionRatio) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrCompressionRatio, err)
+	}
+}
+
+func TestArchive_Manifest(t *testing.T) {
+	tmp := t.TempDir()
+	extractPath := filepath.Join(tmp, "extract")
+
+	filePath := filepath.Join(tmp, "data", "file")
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		t.Fatal("Failed to create directory:", err)
+	}
+
+	if err := os.WriteFile(filePath, []byte("some text"), 0644); err != nil {
+		t.Fatal("Failed on creating temporary file:", err)
+	}
+
+	archivePath, err := Archive(tmp, filepath.Join(tmp, "packet.zip"), []string{filePath})
+	if err != nil {
+		t.Fatal("Failed during archivation:", err)
+	}
+
+	packManifest, err := ReadManifest(archivePath)
+	if err != nil {
+		t.Fatal("Failed to read manifest:", err)
+	}
+
+	if len(packManifest.Entries) != 1 || packManifest.Entries[0].Path != "data/file" || packManifest.Entries[0].Size != 9 {
+		t.Fatalf("Invalid manifest: %+v", packManifest.Entries)
+	}
+
+	if err := ExtractFrom(archivePath, extractPath); err != nil {
+		t.Fatal("Failed to extract files from the archive:", err)
+	}
+
+	report, err := packManifest.Verify(extractPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.OK() {
+		t.Fatalf("Extracted files differ from the manifest: %+v", report)
+	}
+}
+
+func TestArchive_Formats(t *testing.T) {
+	for _, format := range []Format{FormatZip, FormatTarGz, FormatTarZst} {
+		t.Run(string(format), func(t *testing.T) {
+			tmp := t.TempDir()
+			extractPath := filepath.Join(tmp, "extract")
+
+			filePath := filepath.Join(tmp, "data", "file")
+			if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+				t.Fatal("Failed to create directory:", err)
+			}
+
+			if err := os.WriteFile(filePath, []byte("some text"), 0644); err != nil {
+				t.Fatal("Failed on creating temporary file:", err)
+			}
+
+			archivePath, err := Archive(tmp, filepath.Join(tmp, "packet"+format.Extension()), []string{filePath})
+			if err != nil {
+				t.Fatal("Failed during archivation:", err)
+			}
+
+			packManifest, err := ReadManifest(archivePath)
+			if err != nil {
+				t.Fatal("Failed to read manifest:", err)
+			}
+
+			if err := ExtractFrom(archivePath, extractPath); err != nil {
+				t.Fatal("Failed to extract files from the archive:", err)
+			}
+
+			report, err := packManifest.Verify(extractPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !report.OK() || len(packManifest.Entries) != 1 {
+				t.Fatalf("Extracted files differ from the manifest: %+v", report)
+			}
+		})
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	cases := map[string]Format{
+		"packet.zip":     FormatZip,
+		"packet.tar.gz":  FormatTarGz,
+		"packet.tar.zst": FormatTarZst,
+		"packet":         FormatZip,
+	}
+
+	for name, expected := range cases {
+		if format := FormatOf(name); format != expected {
+			t.Fatalf("Invalid format of %s: expected='%s'; got='%s'", name, expected, format)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if format, err := ParseFormat(""); err != nil || format != DefaultFormat {
+		t.Fatalf("Invalid default format: %s, %v", format, err)
+	}
+
+	if _, err := ParseFormat("rar"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnsupportedFormat, err)
+	}
+}
+
+func TestExtractFrom_TarSlip(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "archive.tar.gz")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	body := "evil"
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "../../.bashrc", Size: int64(len(body)), Mode: 0644}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte(body))
+	tw.Close()
+	gw.Close()
+	f.Close()
+
+	if err := ExtractFrom(archivePath, t.TempDir()); !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnsafePath, err)
+	}
+}
+
+func TestArchiveWithOptions_PreserveAttributes(t *testing.T) {
+	modTime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	for _, format := range []Format{FormatZip, FormatTarGz, FormatTarZst} {
+		t.Run(string(format), func(t *testing.T) {
+			tmp := t.TempDir()
+			src := filepath.Join(tmp, "src")
+			extractPath := filepath.Join(tmp, "extract")
+
+			script := filepath.Join(src, "run.sh")
+			link := filepath.Join(src, "run")
+			emptyDir := filepath.Join(src, "empty")
+			if err := os.MkdirAll(emptyDir, os.ModePerm); err != nil {
+				t.Fatal("Failed to create directory:", err)
+			}
+			if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+				t.Fatal("Failed on creating temporary file:", err)
+			}
+			if err := os.Chmod(script, 0750); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(script, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink("run.sh", link); err != nil {
+				t.Fatal("Failed to create symbolic link:", err)
+			}
+
+			archivePath, err := Archive(src, filepath.Join(tmp, "packet"+format.Extension()), []string{script, link, emptyDir})
+			if err != nil {
+				t.Fatal("Failed during archivation:", err)
+			}
+
+			if err := ExtractFrom(archivePath, extractPath); err != nil {
+				t.Fatal("Failed to extract files from the archive:", err)
+			}
+
+			info, err := os.Stat(filepath.Join(extractPath, "run.sh"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0750 {
+				t.Fatalf("Mode is not restored: %v", info.Mode())
+			}
+			if !info.ModTime().Equal(modTime) {
+				t.Fatalf("Modification time is not restored: %v", info.ModTime())
+			}
+
+			target, err := os.Readlink(filepath.Join(extractPath, "run"))
+			if err != nil || target != "run.sh" {
+				t.Fatalf("Symbolic link is not restored: '%s', %v", target, err)
+			}
+
+			if info, err := os.Stat(filepath.Join(extractPath, "empty")); err != nil || !info.IsDir() {
+				t.Fatalf("Empty directory is not restored: %v", err)
+			}
+
+			packManifest, err := ReadManifest(archivePath)
+			if err != nil {
+				t.Fatal("Failed to read manifest:", err)
+			}
+
+			report, err := packManifest.Verify(extractPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !packManifest.ModesPreserved || !report.OK() {
+				t.Fatalf("Extracted files differ from the manifest: %+v", report)
+			}
+		})
+	}
+}
+
+func TestArchiveWithOptions_WithoutAttributes(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src")
+	extractPath := filepath.Join(tmp, "extract")
+	modTime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	if err := os.MkdirAll(src, os.ModePerm); err != nil {
+		t.Fatal("Failed to create directory:", err)
+	}
+	script := filepath.Join(src, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0700); err != nil {
+		t.Fatal("Failed on creating temporary file:", err)
+	}
+	if err := os.Chtimes(script, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(src, "run")
+	if err := os.Symlink("run.sh", link); err != nil {
+		t.Fatal("Failed to create symbolic link:", err)
+	}
+
+	archivePath, err := ArchiveWithOptions(src, filepath.Join(tmp, "packet.zip"), []string{script, link}, Options{})
+	if err != nil {
+		t.Fatal("Failed during archivation:", err)
+	}
+
+	if err := ExtractFrom(archivePath, extractPath); err != nil {
+		t.Fatal("Failed to extract files from the archive:", err)
+	}
+
+	info, err := os.Lstat(filepath.Join(extractPath, "run"))
+	if err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("Symbolic link should be stored as a file: %v", err)
+	}
+
+	info, err = os.Stat(filepath.Join(extractPath, "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ModTime().Equal(modTime) {
+		t.Fatal("Modification time should not be restored")
+	}
+	if info.Mode().Perm()&0100 != 0 {
+		t.Fatalf("Mode should not be restored: %v", info.Mode())
+	}
+}
+
+// makeTree creates files a, b/c and d with given mode and modification time
+func makeTree(t *testing.T, dir string, mode os.FileMode, modTime time.Time) []string {
+	names := []string{filepath.Join(dir, "d"), filepath.Join(dir, "b", "c"), filepath.Join(dir, "a")}
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+			t.Fatal("Failed to create directory:", err)
+		}
+		if err := os.WriteFile(name, []byte(filepath.Base(name)), mode); err != nil {
+			t.Fatal("Failed on creating temporary file:", err)
+		}
+		if err := os.Chmod(name, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return names
+}
+
+func TestArchive_Deterministic(t *testing.T) {
+	modTime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	for _, format := range []Format{FormatZip, FormatTarGz, FormatTarZst} {
+		t.Run(string(format), func(t *testing.T) {
+			tmp := t.TempDir()
+			src := filepath.Join(tmp, "src")
+			names := makeTree(t, src, 0644, modTime)
+
+			first, err := Archive(src, filepath.Join(tmp, "first"+format.Extension()), names)
+			if err != nil {
+				t.Fatal("Failed during archivation:", err)
+			}
+
+			reversed := []string{names[2], names[1], names[0]}
+			second, err := Archive(src, filepath.Join(tmp, "second"+format.Extension()), reversed)
+			if err != nil {
+				t.Fatal("Failed during archivation:", err)
+			}
+
+			assertSameFiles(t, first, second)
+		})
+	}
+}
+
+func TestArchiveWithOptions_Reproducible(t *testing.T) {
+	opts := Options{
+		PreserveMode:    true,
+		PreserveModTime: true,
+		NormalizeModes:  true,
+		ModTime:         NormalizedModTime,
+	}
+
+	for _, format := range []Format{FormatZip, FormatTarGz, FormatTarZst} {
+		t.Run(string(format), func(t *testing.T) {
+			tmp := t.TempDir()
+			firstSrc := filepath.Join(tmp, "first")
+			secondSrc := filepath.Join(tmp, "second")
+			firstNames := makeTree(t, firstSrc, 0700, time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+			secondNames := makeTree(t, secondSrc, 0775, time.Now())
+
+			first, err := ArchiveWithOptions(firstSrc, filepath.Join(tmp, "first"+format.Extension()), firstNames, opts)
+			if err != nil {
+				t.Fatal("Failed during archivation:", err)
+			}
+
+			second, err := ArchiveWithOptions(secondSrc, filepath.Join(tmp, "second"+format.Extension()), secondNames, opts)
+			if err != nil {
+				t.Fatal("Failed during archivation:", err)
+			}
+
+			assertSameFiles(t, first, second)
+
+			extractPath := filepath.Join(tmp, "extract")
+			if err := ExtractFrom(first, extractPath); err != nil {
+				t.Fatal("Failed to extract files from the archive:", err)
+			}
+
+			info, err := os.Stat(filepath.Join(extractPath, "a"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0755 || !info.ModTime().Equal(NormalizedModTime) {
+				t.Fatalf("Attributes are not normalized: mode=%v; mtime=%v", info.Mode(), info.ModTime())
+			}
+		})
+	}
+}
+
+func assertSameFiles(t *testing.T, first string, second string) {
+	firstData, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secondData, err := os.ReadFile(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(firstData, secondData) {
+		t.Fatal("Archives of the same files differ")
+	}
+}
+
+func TestSourceDateEpoch(t *testing.T) {
+	t.Setenv(EnvSourceDateEpoch, "")
+	if _, ok, err := SourceDateEpoch(); ok || err != nil {
+		t.Fatalf("Unexpected result of unset variable: %v, %v", ok, err)
+	}
+
+	t.Setenv(EnvSourceDateEpoch, "1600000000")
+	epoch, ok, err := SourceDateEpoch()
+	if err != nil || !ok || epoch.Unix() != 1600000000 {
+		t.Fatalf("Invalid epoch: %v, %v, %v", epoch, ok, err)
+	}
+
+	t.Setenv(EnvSourceDateEpoch, "yesterday")
+	if _, _, err := SourceDateEpoch(); !errors.Is(err, ErrInvalidSourceDateEpoch) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidSourceDateEpoch, err)
+	}
+}
+
+func TestArchiveTo_SameAsFile(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src")
+	names := makeTree(t, src, 0644, time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+
+	archivePath, err := Archive(src, filepath.Join(tmp, "packet.tar.gz"), names)
+	if err != nil {
+		t.Fatal("Failed during archivation:", err)
+	}
+
+	var streamed bytes.Buffer
+	if _, err := ArchiveTo(&streamed, FormatTarGz, src, names, DefaultOptions); err != nil {
+		t.Fatal("Failed during archivation:", err)
+	}
+
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, streamed.Bytes()) {
+		t.Fatal("Streamed archive differs from the archive file")
+	}
+}
+
+func TestExtractStream(t *testing.T) {
+	for _, format := range []Format{FormatTarGz, FormatTarZst} {
+		t.Run(string(format), func(t *testing.T) {
+			tmp := t.TempDir()
+			src := filepath.Join(tmp, "src")
+			names := makeTree(t, src, 0644, time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+
+			var stream bytes.Buffer
+			if _, err := ArchiveTo(&stream, format, src, names, DefaultOptions); err != nil {
+				t.Fatal("Failed during archivation:", err)
+			}
+
+			dst := filepath.Join(tmp, "dst")
+			m, err := ExtractStream(&stream, format, dst, DefaultLimits)
+			if err != nil {
+				t.Fatal("Failed to extract stream:", err)
+			}
+			if m == nil || len(m.Entries) != len(names) {
+				t.Fatalf("Expected manifest of %d files, got: %+v", len(names), m)
+			}
+
+			for _, name := range names {
+				rel, _ := filepath.Rel(src, name)
+				assertSameFiles(t, name, filepath.Join(dst, rel))
+			}
+		})
+	}
+}
+
+// TestExtractStream_SymlinkChain checks that links could not redirect files of a stream
+// which is extracted before its checksum and signature are verified
+func TestExtractStream_SymlinkChain(t *testing.T) {
+	for _, chain := range [][]*tar.Header{
+		{
+			{Typeflag: tar.TypeDir, Name: "sub/", Mode: 0755},
+			{Typeflag: tar.TypeSymlink, Name: "sub/y", Linkname: "..", Mode: 0777},
+			{Typeflag: tar.TypeSymlink, Name: "sub/z", Linkname: "y/../..", Mode: 0777},
+			{Typeflag: tar.TypeReg, Name: "sub/z/escaped.txt", Mode: 0644},
+		},
+		{
+			{Typeflag: tar.TypeSymlink, Name: "sub", Linkname: ".", Mode: 0777},
+			{Typeflag: tar.TypeReg, Name: "sub/escaped.txt", Mode: 0644},
+		},
+	} {
+		var stream bytes.Buffer
+		gw := gzip.NewWriter(&stream)
+		tw := tar.NewWriter(gw)
+		for _, header := range chain {
+			if err := tw.WriteHeader(header); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tw.Close()
+		gw.Close()
+
+		dst := filepath.Join(t.TempDir(), "a", "b")
+		_, err := ExtractStream(&stream, FormatTarGz, dst, DefaultLimits)
+		if !errors.Is(err, ErrUnsafePath) && !errors.Is(err, ErrUnsafeSymlink) {
+			t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnsafePath, err)
+		}
+
+		for _, dir := range []string{dst, filepath.Dir(dst), filepath.Dir(filepath.Dir(dst))} {
+			if _, err := os.Lstat(filepath.Join(dir, "escaped.txt")); err == nil {
+				t.Fatalf("File was written through a link to %s", dir)
+			}
+		}
+	}
+}
+
+func TestExtractStream_Zip(t *testing.T) {
+	_, err := ExtractStream(bytes.NewReader(nil), FormatZip, t.TempDir(), DefaultLimits)
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatal("Expected ErrUnsupportedFormat, got:", err)
+	}
+}
+
+func TestArchiveTo_SameForAnyWorkers(t *testing.T) {
+	src := t.TempDir()
+	names := makeTree(t, src, 0644, time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC))
+	large := filepath.Join(src, "large")
+	content := make([]byte, 2*parallelSizeLimit)
+	rand.New(rand.NewSource(1)).Read(content)
+	if err := os.WriteFile(large, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	names = append(names, large)
+
+	for _, format := range formats {
+		t.Run(string(format), func(t *testing.T) {
+			var single, parallel bytes.Buffer
+			opts := DefaultOptions
+			opts.Workers = 1
+			if _, err := ArchiveTo(&single, format, src, names, opts); err != nil {
+				t.Fatal("Failed during archivation:", err)
+			}
+
+			opts.Workers = 8
+			m, err := ArchiveTo(&parallel, format, src, names, opts)
+			if err != nil {
+				t.Fatal("Failed during archivation:", err)
+			}
+
+			if !bytes.Equal(single.Bytes(), parallel.Bytes()) {
+				t.Fatal("Archive depends on the number of workers")
+			}
+
+			if len(m.Entries) != len(names) {
+				t.Fatalf("Expected manifest of %d files, got %d", len(names), len(m.Entries))
+			}
+
+			archivePath := filepath.Join(t.TempDir(), "packet"+format.Extension())
+			if err := os.WriteFile(archivePath, parallel.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			dst := t.TempDir()
+			if err := ExtractFrom(archivePath, dst); err != nil {
+				t.Fatal("Failed to extract archive:", err)
+			}
+
+			for _, name := range names {
+				rel, _ := filepath.Rel(src, name)
+				assertSameFiles(t, name, filepath.Join(dst, rel))
+			}
+		})
+	}
+}
+
+func TestArchiveTo_CompressionLevel(t *testing.T) {
+	src := t.TempDir()
+	names := makeTree(t, src, 0644, time.Time{})
+
+	for _, level := range []int{1, MaxCompressionLevel} {
+		opts := DefaultOptions
+		opts.CompressionLevel = level
+		if _, err := ArchiveTo(io.Discard, FormatTarZst, src, names, opts); err != nil {
+			t.Fatalf("Failed to archive with level %d: %v", level, err)
+		}
+	}
+
+	opts := DefaultOptions
+	opts.CompressionLevel = MaxCompressionLevel + 1
+	if _, err := ArchiveTo(io.Discard, FormatZip, src, names, opts); !errors.Is(err, ErrInvalidCompressionLevel) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidCompressionLevel, err)
+	}
+}
+
+func TestArchiveTo_PathsInArchive(t *testing.T) {
+	src := t.TempDir()
+	names := []string{filepath.Join(src, "a.txt"), filepath.Join(src, "shared", "b.txt")}
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := DefaultOptions
+	opts.PathsInArchive = map[string]string{names[1]: "config/b.txt"}
+	packManifest, err := ArchiveTo(io.Discard, FormatTarGz, src, names, opts)
+	if err != nil {
+		t.Fatal("Failed to archive files:", err)
+	}
+
+	if len(packManifest.Entries) != 2 || packManifest.Entries[0].Path != "a.txt" || packManifest.Entries[1].Path != "config/b.txt" {
+		t.Fatalf("Invalid paths in archive: %+v", packManifest.Entries)
+	}
+}
+
+func TestArchiveTo_FollowedLinks(t *testing.T) {
+	src := t.TempDir()
+	data := filepath.Join(src, "data.txt")
+	if err := os.WriteFile(data, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	followed, preserved := filepath.Join(src, "followed"), filepath.Join(src, "preserved")
+	for _, link := range []string{followed, preserved} {
+		if err := os.Symlink("data.txt", link); err != nil {
+			t.Fatal("Failed to create symbolic link:", err)
+		}
+	}
+
+	opts := DefaultOptions
+	opts.PreserveSymlinks = true
+	opts.FollowedLinks = map[string]bool{followed: true}
+	packManifest, err := ArchiveTo(io.Discard, FormatTarGz, src, []string{data, followed, preserved}, opts)
+	if err != nil {
+		t.Fatal("Failed to archive files:", err)
+	}
+
+	entries := packManifest.Entries
+	if len(entries) != 3 || entries[0].SHA256 != entries[1].SHA256 || !entries[1].Mode.IsRegular() {
+		t.Fatalf("Followed link is not archived as a file: %+v", entries)
+	}
+	if entries[2].Mode&os.ModeSymlink == 0 {
+		t.Fatalf("Preserved link is not archived as a link: %+v", entries[2])
+	}
+}
@@ -0,0 +1,127 @@
+package archiver
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+	"github.com/Elementary1092/pm/internal/packet/manifest"
+)
+
+// parallelSizeLimit is the largest file which is compressed in memory by a worker.
+// Larger files are compressed while they are written, so memory usage is bounded by workers*parallelSizeLimit.
+const parallelSizeLimit = 4 << 20
+
+// parallelCompressor compresses regular files in advance for formats which compress every file separately.
+// Files are compressed concurrently and taken in the order of the archive entries.
+type parallelCompressor struct {
+	writer rawArchiveWriter
+	// results is nil for files which are not compressed in advance
+	results []chan compressResult
+	// slots limit the number of files which are compressed or wait to be written
+	slots chan struct{}
+	done  chan struct{}
+}
+
+type compressResult struct {
+	file  *compressedFile
+	entry manifest.Entry
+	err   error
+}
+
+// startParallelCompressor returns nil if archiveWriter compresses the whole stream at once
+func startParallelCompressor(archiveWriter archiveWriter, files []archivedFile, opts Options) *parallelCompressor {
+	writer, ok := archiveWriter.(rawArchiveWriter)
+	if !ok {
+		return nil
+	}
+
+	pc := &parallelCompressor{
+		writer:  writer,
+		results: make([]chan compressResult, len(files)),
+		slots:   make(chan struct{}, opts.workers()),
+		done:    make(chan struct{}),
+	}
+	for i, file := range files {
+		if file.info.Mode().IsRegular() && file.info.Size() <= parallelSizeLimit {
+			pc.results[i] = make(chan compressResult, 1)
+		}
+	}
+
+	go func() {
+		for i, file := range files {
+			if pc.results[i] == nil {
+				continue
+			}
+
+			select {
+			case pc.slots <- struct{}{}:
+			case <-pc.done:
+				return
+			}
+
+			go func(result chan<- compressResult, file archivedFile) {
+				result <- compressFile(writer, file, opts)
+			}(pc.results[i], file)
+		}
+	}()
+
+	return pc
+}
+
+// take writes file i to the archive if it is compressed in advance.
+// false is returned if the file should be archived by the caller.
+func (pc *parallelCompressor) take(i int) (manifest.Entry, bool, error) {
+	if pc == nil || pc.results[i] == nil {
+		return manifest.Entry{}, false, nil
+	}
+
+	result := <-pc.results[i]
+	<-pc.slots
+	if result.err != nil {
+		return manifest.Entry{}, true, result.err
+	}
+
+	if err := pc.writer.CreateRaw(result.file); err != nil {
+		return manifest.Entry{}, true, ErrFailedToCreateCompressedFile
+	}
+
+	return result.entry, true, nil
+}
+
+// stop does not wait for running workers, their results are dropped
+func (pc *parallelCompressor) stop() {
+	if pc != nil {
+		close(pc.done)
+	}
+}
+
+func compressFile(writer rawArchiveWriter, file archivedFile, opts Options) compressResult {
+	f, err := os.Open(file.name)
+	if err != nil {
+		return compressResult{err: ErrFailedToOpenFile}
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return compressResult{err: ErrFailedToOpenFile}
+	}
+
+	digest := checksum.NewWriter()
+	compressed, err := writer.Compress(makeHeader(file.pathInArchive, info, opts), io.TeeReader(f, digest))
+	if err != nil {
+		return compressResult{err: ErrFailedToCreateCompressedFile}
+	}
+
+	return compressResult{
+		file: compressed,
+		entry: manifest.Entry{
+			Path:   filepath.ToSlash(file.pathInArchive),
+			Size:   digest.Digest().Size,
+			Mode:   storedMode(info.Mode(), opts),
+			SHA256: digest.Digest().Sum,
+		},
+	}
+}
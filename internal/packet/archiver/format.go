@@ -0,0 +1,588 @@
+package archiver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+)
+
+// Format is a kind of archive a package is stored in
+type Format string
+
+const (
+	FormatZip    Format = "zip"
+	FormatTarGz  Format = "tar.gz"
+	FormatTarZst Format = "tar.zst"
+)
+
+// DefaultFormat is used by packages which do not declare a format
+const DefaultFormat = FormatZip
+
+var formats = []Format{FormatZip, FormatTarGz, FormatTarZst}
+
+var (
+	ErrUnsupportedFormat       = errors.New("unsupported archive format")
+	ErrInvalidCompressionLevel = errors.New("invalid compression level")
+)
+
+// MaxCompressionLevel gives the smallest archives, 1 is the fastest level.
+// Level 0 means the default level of a format.
+const MaxCompressionLevel = 9
+
+// tarBlockSize is the size of blocks of a tar stream which are compressed concurrently
+const tarBlockSize = 1 << 20
+
+// ParseFormat returns DefaultFormat for an empty string
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return DefaultFormat, nil
+	}
+
+	for _, f := range formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, s)
+}
+
+// FormatOf detects format by extension of an archive name.
+// DefaultFormat is returned for unknown extensions.
+func FormatOf(archiveName string) Format {
+	for _, f := range formats {
+		if strings.HasSuffix(archiveName, f.Extension()) {
+			return f
+		}
+	}
+
+	return DefaultFormat
+}
+
+// Streamable reports whether an archive could be extracted while it is being read
+func (f Format) Streamable() bool {
+	return f == FormatTarGz || f == FormatTarZst
+}
+
+// Extension returns file name extension with a leading dot
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// header describes a regular file, a directory or a symbolic link added to an archive
+type header struct {
+	Name string
+	// Size of a regular file
+	Size int64
+	// Mode holds file type, permission bits are stored only if ModeSet is true
+	Mode    fs.FileMode
+	ModeSet bool
+	// ModTime is not stored if it is zero
+	ModTime time.Time
+	// Linkname is a target of a symbolic link
+	Linkname string
+}
+
+// defaultPerm is used by formats which always store permission bits
+func defaultPerm(mode fs.FileMode) fs.FileMode {
+	switch {
+	case mode.IsDir():
+		return 0755
+	case mode&fs.ModeSymlink != 0:
+		return 0777
+	}
+
+	return 0644
+}
+
+// archiveWriter adds files to an archive.
+// Writer returned by Create is valid until the next call of Create or Close.
+type archiveWriter interface {
+	Create(h header) (io.Writer, error)
+	Close() error
+}
+
+// rawArchiveWriter is implemented by writers of formats which compress every file separately.
+// Compress could be called concurrently, compressed files are added by CreateRaw in the order of entries.
+type rawArchiveWriter interface {
+	Compress(h header, r io.Reader) (*compressedFile, error)
+	CreateRaw(c *compressedFile) error
+}
+
+// compressedFile is a regular file compressed before it is added to an archive
+type compressedFile struct {
+	header header
+	data   []byte
+	crc32  uint32
+	size   int64
+}
+
+// newArchiveWriter compresses with at most workers goroutines.
+// Output does not depend on the number of workers.
+func newArchiveWriter(w io.Writer, format Format, level int, workers int) (archiveWriter, error) {
+	if level < 0 || level > MaxCompressionLevel {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidCompressionLevel, level)
+	}
+
+	switch format {
+	case FormatZip:
+		return newZipArchiveWriter(w, flateLevel(level)), nil
+	case FormatTarGz:
+		gw, err := pgzip.NewWriterLevel(w, flateLevel(level))
+		if err != nil {
+			return nil, ErrFailedToCreateArchieve
+		}
+		if err := gw.SetConcurrency(tarBlockSize, workers); err != nil {
+			return nil, ErrFailedToCreateArchieve
+		}
+		return newTarArchiveWriter(gw), nil
+	case FormatTarZst:
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel(level)), zstd.WithEncoderConcurrency(workers))
+		if err != nil {
+			return nil, ErrFailedToCreateArchieve
+		}
+		return newTarArchiveWriter(zw), nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+}
+
+func flateLevel(level int) int {
+	if level == 0 {
+		return flate.DefaultCompression
+	}
+
+	return level
+}
+
+func zstdLevel(level int) zstd.EncoderLevel {
+	switch {
+	case level == 0:
+		return zstd.SpeedDefault
+	case level <= 2:
+		return zstd.SpeedFastest
+	case level <= 5:
+		return zstd.SpeedDefault
+	case level <= 7:
+		return zstd.SpeedBetterCompression
+	}
+
+	return zstd.SpeedBestCompression
+}
+
+type zipArchiveWriter struct {
+	zw    *zip.Writer
+	level int
+	// compressors are reused, because every deflate writer allocates large buffers
+	compressors sync.Pool
+}
+
+func newZipArchiveWriter(w io.Writer, level int) *zipArchiveWriter {
+	zaw := &zipArchiveWriter{
+		zw:    zip.NewWriter(w),
+		level: level,
+	}
+	zaw.zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(out, level)
+	})
+
+	return zaw
+}
+
+// Create stores target of a symbolic link as its content
+func (w *zipArchiveWriter) Create(h header) (io.Writer, error) {
+	fw, err := w.zw.CreateHeader(fileHeader(h))
+	if err != nil {
+		return nil, err
+	}
+
+	if h.Mode&fs.ModeSymlink != 0 {
+		if _, err := io.WriteString(fw, h.Linkname); err != nil {
+			return nil, err
+		}
+	}
+
+	return fw, nil
+}
+
+// Compress deflates a regular file the same way as it is deflated by Create
+func (w *zipArchiveWriter) Compress(h header, r io.Reader) (*compressedFile, error) {
+	var compressed bytes.Buffer
+	fw, ok := w.compressors.Get().(*flate.Writer)
+	if ok {
+		fw.Reset(&compressed)
+	} else {
+		var err error
+		if fw, err = flate.NewWriter(&compressed, w.level); err != nil {
+			return nil, err
+		}
+	}
+	defer w.compressors.Put(fw)
+
+	crc := crc32.NewIEEE()
+	size, err := io.Copy(io.MultiWriter(fw, crc), r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+
+	return &compressedFile{
+		header: h,
+		data:   compressed.Bytes(),
+		crc32:  crc.Sum32(),
+		size:   size,
+	}, nil
+}
+
+func (w *zipArchiveWriter) CreateRaw(c *compressedFile) error {
+	fh, err := preparedFileHeader(c.header)
+	if err != nil {
+		return err
+	}
+
+	fh.CRC32 = c.crc32
+	fh.CompressedSize64 = uint64(len(c.data))
+	fh.UncompressedSize64 = uint64(c.size)
+	raw, err := w.zw.CreateRaw(fh)
+	if err != nil {
+		return err
+	}
+
+	_, err = raw.Write(c.data)
+	return err
+}
+
+// preparedFileHeader fills in fields which are set by zip.Writer.CreateHeader,
+// but are left as is by zip.Writer.CreateRaw
+func preparedFileHeader(h header) (*zip.FileHeader, error) {
+	fh := fileHeader(h)
+	scratch := zip.NewWriter(io.Discard)
+	scratch.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+		return nopWriteCloser{out}, nil
+	})
+	if _, err := scratch.CreateHeader(fh); err != nil {
+		return nil, err
+	}
+
+	return fh, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func fileHeader(h header) *zip.FileHeader {
+	// MS-DOS time is stored in the time zone of the given time
+	fh := &zip.FileHeader{
+		Name:     h.Name,
+		Method:   zip.Deflate,
+		Modified: h.ModTime.UTC(),
+	}
+
+	isSymlink := h.Mode&fs.ModeSymlink != 0
+	if h.Mode.IsDir() {
+		fh.Name += "/"
+		fh.Method = zip.Store
+	}
+
+	// file type of a link could not be stored without mode
+	if h.ModeSet || isSymlink {
+		mode := h.Mode
+		if !h.ModeSet {
+			mode = h.Mode.Type() | defaultPerm(h.Mode)
+		}
+		fh.SetMode(mode)
+	}
+
+	return fh
+}
+
+func (w *zipArchiveWriter) Close() error {
+	return w.zw.Close()
+}
+
+// tarArchiveWriter writes tar stream to a compressor
+type tarArchiveWriter struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+	closed     bool
+}
+
+func newTarArchiveWriter(compressor io.WriteCloser) *tarArchiveWriter {
+	return &tarArchiveWriter{
+		tw:         tar.NewWriter(compressor),
+		compressor: compressor,
+	}
+}
+
+// Create stores default permission bits if they are not preserved
+// and Unix epoch if modification time is not preserved.
+// Owner is never stored, so archives do not depend on the user who created them.
+func (w *tarArchiveWriter) Create(h header) (io.Writer, error) {
+	th := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     h.Name,
+		Size:     h.Size,
+		Mode:     int64(h.Mode.Perm()),
+		ModTime:  h.ModTime,
+	}
+
+	if !h.ModeSet {
+		th.Mode = int64(defaultPerm(h.Mode))
+	}
+
+	if h.ModTime.IsZero() {
+		th.ModTime = time.Unix(0, 0)
+	}
+
+	switch {
+	case h.Mode.IsDir():
+		th.Typeflag = tar.TypeDir
+		th.Name += "/"
+		th.Size = 0
+	case h.Mode&fs.ModeSymlink != 0:
+		th.Typeflag = tar.TypeSymlink
+		th.Linkname = h.Linkname
+		th.Size = 0
+	}
+
+	err := w.tw.WriteHeader(th)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.tw, nil
+}
+
+// Close could be called more than once
+func (w *tarArchiveWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if err := w.tw.Close(); err != nil {
+		w.compressor.Close()
+		return err
+	}
+
+	return w.compressor.Close()
+}
+
+// entry is a file read from an archive.
+// Content of a symbolic link is its target.
+type entry struct {
+	Name string
+	// Mode holds file type, permission bits should be restored only if ModeSet is true
+	Mode    fs.FileMode
+	ModeSet bool
+	// ModTime is zero if it is not stored
+	ModTime time.Time
+	// Size is declared uncompressed size, it could be forged
+	Size int64
+	Open func() (io.ReadCloser, error)
+}
+
+// archiveReader iterates over entries of an archive.
+// Next returns io.EOF after the last entry. Entry is valid until the next call of Next.
+type archiveReader interface {
+	Next() (*entry, error)
+	// Check validates limits before extraction if the format allows it,
+	// limits are checked while extracting anyway
+	Check(limits Limits) error
+	Close() error
+}
+
+func openArchiveReader(archiveFullName string) (archiveReader, error) {
+	format := FormatOf(archiveFullName)
+	if format == FormatZip {
+		zr, err := zip.OpenReader(archiveFullName)
+		if err != nil {
+			return nil, ErrFailedToOpenFile
+		}
+		return &zipArchiveReader{zr: zr}, nil
+	}
+
+	f, err := os.Open(archiveFullName)
+	if err != nil {
+		return nil, ErrFailedToOpenFile
+	}
+
+	reader, err := newTarArchiveReader(f, format)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	reader.file = f
+
+	return reader, nil
+}
+
+// newTarArchiveReader decompresses tar stream of the given format
+func newTarArchiveReader(r io.Reader, format Format) (*tarArchiveReader, error) {
+	switch format {
+	case FormatTarGz:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, ErrFailedToOpenFile
+		}
+		return &tarArchiveReader{
+			tr:                tar.NewReader(gr),
+			closeDecompressor: func() {},
+		}, nil
+	case FormatTarZst:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, ErrFailedToOpenFile
+		}
+		return &tarArchiveReader{
+			tr:                tar.NewReader(zr),
+			closeDecompressor: zr.Close,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s could not be read as a stream", ErrUnsupportedFormat, format)
+}
+
+// zipCreatorUnix is "version made by" of archives which store Unix mode bits
+const zipCreatorUnix = 3
+
+type zipArchiveReader struct {
+	zr   *zip.ReadCloser
+	next int
+}
+
+func (r *zipArchiveReader) Next() (*entry, error) {
+	if r.next >= len(r.zr.File) {
+		return nil, io.EOF
+	}
+
+	f := r.zr.File[r.next]
+	r.next++
+
+	e := &entry{
+		Name:    f.Name,
+		Mode:    f.Mode(),
+		ModeSet: f.CreatorVersion>>8 == zipCreatorUnix,
+		Size:    int64(f.UncompressedSize64),
+		Open: func() (io.ReadCloser, error) {
+			return f.Open()
+		},
+	}
+
+	// archives created without modification times have zero MS-DOS date
+	if f.ModifiedDate != 0 {
+		e.ModTime = f.Modified
+	}
+
+	return e, nil
+}
+
+// Check uses declared sizes of all files which are stored in the central directory
+func (r *zipArchiveReader) Check(limits Limits) error {
+	return checkLimits(r.zr.File, limits)
+}
+
+func (r *zipArchiveReader) Close() error {
+	return r.zr.Close()
+}
+
+// tarArchiveReader reads a compressed tar stream.
+// Sizes are unknown until the whole stream is read, so limits are checked only while extracting.
+type tarArchiveReader struct {
+	tr                *tar.Reader
+	closeDecompressor func()
+	// file is nil if the stream is not read from a file
+	file *os.File
+}
+
+func (r *tarArchiveReader) Next() (*entry, error) {
+	for {
+		h, err := r.tr.Next()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, ErrFailedToExtractFile
+		}
+
+		var modTime time.Time
+		if h.ModTime.Unix() != 0 {
+			modTime = h.ModTime
+		}
+
+		switch h.Typeflag {
+		case tar.TypeReg, tar.TypeRegA:
+			return &entry{
+				Name:    h.Name,
+				Mode:    fs.FileMode(h.Mode).Perm(),
+				ModeSet: true,
+				ModTime: modTime,
+				Size:    h.Size,
+				Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(r.tr), nil
+				},
+			}, nil
+		case tar.TypeDir:
+			return &entry{
+				Name:    h.Name,
+				Mode:    fs.ModeDir | fs.FileMode(h.Mode).Perm(),
+				ModeSet: true,
+				ModTime: modTime,
+				Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader("")), nil
+				},
+			}, nil
+		case tar.TypeSymlink:
+			target := h.Linkname
+			return &entry{
+				Name:    h.Name,
+				Mode:    fs.ModeSymlink | fs.FileMode(h.Mode).Perm(),
+				ModeSet: true,
+				ModTime: modTime,
+				Size:    int64(len(target)),
+				Open: func() (io.ReadCloser, error) {
+					return io.NopCloser(strings.NewReader(target)), nil
+				},
+			}, nil
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return nil, fmt.Errorf("%w: unsupported entry type of %s", ErrFailedToExtractFile, h.Name)
+		}
+	}
+}
+
+func (r *tarArchiveReader) Check(limits Limits) error {
+	return nil
+}
+
+func (r *tarArchiveReader) Close() error {
+	r.closeDecompressor()
+	if r.file == nil {
+		return nil
+	}
+
+	return r.file.Close()
+}
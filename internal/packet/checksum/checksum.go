@@ -0,0 +1,89 @@
+package checksum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"os"
+)
+
+var (
+	ErrFailedToOpenFile = errors.New("failed to open file")
+	ErrFailedToReadFile = errors.New("failed to read file")
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrSizeMismatch     = errors.New("size mismatch")
+)
+
+// Digest is a SHA-256 checksum with the size of the checked data
+type Digest struct {
+	Sum  string
+	Size int64
+}
+
+// Writer computes Digest of everything written to it
+type Writer struct {
+	hash hash.Hash
+	size int64
+}
+
+func NewWriter() *Writer {
+	return &Writer{
+		hash: sha256.New(),
+	}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.hash.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *Writer) Digest() Digest {
+	return Digest{
+		Sum:  hex.EncodeToString(w.hash.Sum(nil)),
+		Size: w.size,
+	}
+}
+
+func Compute(data io.Reader) (Digest, error) {
+	w := NewWriter()
+	if _, err := io.Copy(w, data); err != nil {
+		return Digest{}, ErrFailedToReadFile
+	}
+
+	return w.Digest(), nil
+}
+
+func ComputeFile(filePath string) (Digest, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return Digest{}, ErrFailedToOpenFile
+	}
+	defer f.Close()
+
+	return Compute(f)
+}
+
+// Verify compares expected digest with the actual one
+func (d Digest) Verify(actual Digest) error {
+	if d.Size != actual.Size {
+		return ErrSizeMismatch
+	}
+
+	if d.Sum != actual.Sum {
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
+
+func VerifyFile(filePath string, expected Digest) error {
+	actual, err := ComputeFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	return expected.Verify(actual)
+}
@@ -0,0 +1,56 @@
+package checksum
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// sha256 of "some text"
+const someTextSum = "b94f6f125c79e3a5ffaa826f584c10d52ada669e6762051b826b55776d05aed2"
+
+func TestCompute(t *testing.T) {
+	digest, err := Compute(strings.NewReader("some text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digest.Sum != someTextSum || digest.Size != 9 {
+		t.Fatalf("Invalid digest: sum='%s'; size=%d", digest.Sum, digest.Size)
+	}
+}
+
+func TestVerifyFile_Valid(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(filePath, []byte("some text"), 0644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	if err := VerifyFile(filePath, Digest{Sum: someTextSum, Size: 9}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyFile_Modified(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(filePath, []byte("some test"), 0644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	if err := VerifyFile(filePath, Digest{Sum: someTextSum, Size: 9}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrChecksumMismatch, err)
+	}
+}
+
+func TestVerifyFile_Truncated(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(filePath, []byte("some"), 0644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	if err := VerifyFile(filePath, Digest{Sum: someTextSum, Size: 9}); !errors.Is(err, ErrSizeMismatch) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrSizeMismatch, err)
+	}
+}
@@ -0,0 +1,179 @@
+package delta
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Elementary1092/pm/internal/packet/manifest"
+)
+
+var (
+	ErrFailedToCopyFile = errors.New("failed to copy unchanged file")
+	ErrDeltaMismatch    = errors.New("package assembled from delta differs from its manifest")
+)
+
+// Diff lists files which differ between two versions of a package.
+// Paths are relative to the package root and use forward slashes.
+type Diff struct {
+	Added   []string
+	Changed []string
+	Removed []string
+	// Unchanged is the number of files which are taken from the installed version
+	Unchanged int
+}
+
+// Compare finds files of next which are absent in previous or have a different content or mode
+func Compare(previous *manifest.Manifest, next *manifest.Manifest) *Diff {
+	previousEntries := make(map[string]manifest.Entry, len(previous.Entries))
+	for _, entry := range previous.Entries {
+		previousEntries[entry.Path] = entry
+	}
+
+	diff := &Diff{}
+	for _, entry := range next.Entries {
+		old, ok := previousEntries[entry.Path]
+		delete(previousEntries, entry.Path)
+
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, entry.Path)
+		case old.SHA256 != entry.SHA256 || old.Size != entry.Size || old.Mode != entry.Mode:
+			diff.Changed = append(diff.Changed, entry.Path)
+		default:
+			diff.Unchanged++
+		}
+	}
+
+	for path := range previousEntries {
+		diff.Removed = append(diff.Removed, path)
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.Removed)
+
+	return diff
+}
+
+// Shipped returns files which should be stored in the delta archive
+func (d *Diff) Shipped() []string {
+	shipped := make([]string, 0, len(d.Added)+len(d.Changed))
+	shipped = append(shipped, d.Added...)
+	shipped = append(shipped, d.Changed...)
+	sort.Strings(shipped)
+
+	return shipped
+}
+
+// Useful reports whether the delta is smaller than the full package
+func (d *Diff) Useful() bool {
+	return d.Unchanged > 0
+}
+
+// Apply assembles the next version of a package in staging.
+// Files stored in the delta archive should already be extracted to staging,
+// every other file of next is taken from installedDir: it is hard linked if possible and copied otherwise.
+// The result is verified against next, so files modified after installation are never carried over.
+func Apply(installedDir string, staging string, next *manifest.Manifest) error {
+	for _, entry := range next.Entries {
+		target := filepath.Join(staging, filepath.FromSlash(entry.Path))
+		if _, err := os.Lstat(target); err == nil {
+			continue
+		}
+
+		source := filepath.Join(installedDir, filepath.FromSlash(entry.Path))
+		if err := carryOver(source, target); err != nil {
+			return fmt.Errorf("%w: %s", err, entry.Path)
+		}
+	}
+
+	report, err := next.Verify(staging)
+	if err != nil {
+		return err
+	}
+
+	if !report.OK() {
+		return fmt.Errorf("%w: %s", ErrDeltaMismatch, strings.Join(mismatched(report), ", "))
+	}
+
+	return nil
+}
+
+func carryOver(source string, target string) error {
+	info, err := os.Lstat(source)
+	if err != nil {
+		return ErrFailedToCopyFile
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return ErrFailedToCopyFile
+	}
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		linkTarget, err := os.Readlink(source)
+		if err != nil {
+			return ErrFailedToCopyFile
+		}
+
+		if err := os.Symlink(linkTarget, target); err != nil {
+			return ErrFailedToCopyFile
+		}
+
+		return nil
+	}
+
+	// installed directory is replaced as a whole, so sharing files with it is safe
+	if err := os.Link(source, target); err == nil {
+		return nil
+	}
+
+	return copyFile(source, target, info)
+}
+
+// copyFile keeps mode and modification time of the source
+func copyFile(source string, target string, info fs.FileInfo) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return ErrFailedToCopyFile
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return ErrFailedToCopyFile
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return ErrFailedToCopyFile
+	}
+
+	if err := dst.Close(); err != nil {
+		return ErrFailedToCopyFile
+	}
+
+	if err := os.Chmod(target, info.Mode().Perm()); err != nil {
+		return ErrFailedToCopyFile
+	}
+
+	if err := os.Chtimes(target, info.ModTime(), info.ModTime()); err != nil {
+		return ErrFailedToCopyFile
+	}
+
+	return nil
+}
+
+func mismatched(report *manifest.Report) []string {
+	paths := make([]string, 0, len(report.Modified)+len(report.Missing)+len(report.Extra))
+	paths = append(paths, report.Modified...)
+	paths = append(paths, report.Missing...)
+	paths = append(paths, report.Extra...)
+
+	return paths
+}
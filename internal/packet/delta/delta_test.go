@@ -0,0 +1,136 @@
+package delta
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+	"github.com/Elementary1092/pm/internal/packet/manifest"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func makeManifest(t *testing.T, root string, files map[string]string) *manifest.Manifest {
+	m := &manifest.Manifest{}
+	for name := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		digest, err := checksum.ComputeFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Add(manifest.Entry{Path: name, Size: digest.Size, Mode: info.Mode(), SHA256: digest.Sum})
+	}
+	m.Sort()
+
+	return m
+}
+
+func TestCompare(t *testing.T) {
+	previous := &manifest.Manifest{Entries: []manifest.Entry{
+		{Path: "a", Size: 1, SHA256: "1"},
+		{Path: "b", Size: 1, SHA256: "2"},
+		{Path: "c", Size: 1, SHA256: "3"},
+	}}
+	next := &manifest.Manifest{Entries: []manifest.Entry{
+		{Path: "a", Size: 1, SHA256: "1"},
+		{Path: "b", Size: 2, SHA256: "4"},
+		{Path: "d", Size: 1, SHA256: "5"},
+	}}
+
+	diff := Compare(previous, next)
+	if !reflect.DeepEqual(diff.Added, []string{"d"}) || !reflect.DeepEqual(diff.Changed, []string{"b"}) ||
+		!reflect.DeepEqual(diff.Removed, []string{"c"}) || diff.Unchanged != 1 {
+		t.Fatalf("Invalid diff: %+v", diff)
+	}
+
+	if !reflect.DeepEqual(diff.Shipped(), []string{"b", "d"}) {
+		t.Fatalf("Invalid shipped files: %v", diff.Shipped())
+	}
+
+	if !diff.Useful() {
+		t.Fatal("Delta with unchanged files should be useful")
+	}
+}
+
+func TestCompare_EverythingChanged(t *testing.T) {
+	previous := &manifest.Manifest{Entries: []manifest.Entry{{Path: "a", Size: 1, SHA256: "1"}}}
+	next := &manifest.Manifest{Entries: []manifest.Entry{{Path: "a", Size: 1, SHA256: "2"}}}
+
+	if Compare(previous, next).Useful() {
+		t.Fatal("Delta of changed files only should not be useful")
+	}
+}
+
+func TestApply(t *testing.T) {
+	tmp := t.TempDir()
+	installedDir := filepath.Join(tmp, "installed")
+	writeFiles(t, installedDir, map[string]string{"a": "a", "dir/b": "b", "removed": "r"})
+
+	nextFiles := map[string]string{"a": "a", "dir/b": "changed", "dir/c": "c"}
+	nextDir := filepath.Join(tmp, "next")
+	writeFiles(t, nextDir, nextFiles)
+	next := makeManifest(t, nextDir, nextFiles)
+
+	staging := filepath.Join(tmp, "staging")
+	writeFiles(t, staging, map[string]string{"dir/b": "changed", "dir/c": "c"})
+
+	if err := Apply(installedDir, staging, next); err != nil {
+		t.Fatal("Failed to apply delta:", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(staging, "a"))
+	if err != nil || string(data) != "a" {
+		t.Fatal("Unchanged file is not carried over")
+	}
+
+	if _, err := os.Stat(filepath.Join(staging, "removed")); err == nil {
+		t.Fatal("Removed file is carried over")
+	}
+}
+
+func TestApply_ModifiedInstalledFile(t *testing.T) {
+	tmp := t.TempDir()
+	nextFiles := map[string]string{"a": "a", "b": "b"}
+	nextDir := filepath.Join(tmp, "next")
+	writeFiles(t, nextDir, nextFiles)
+	next := makeManifest(t, nextDir, nextFiles)
+
+	installedDir := filepath.Join(tmp, "installed")
+	writeFiles(t, installedDir, map[string]string{"a": "modified locally"})
+
+	staging := filepath.Join(tmp, "staging")
+	writeFiles(t, staging, map[string]string{"b": "b"})
+
+	if err := Apply(installedDir, staging, next); !errors.Is(err, ErrDeltaMismatch) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrDeltaMismatch, err)
+	}
+}
+
+func TestApply_MissingInstalledFile(t *testing.T) {
+	tmp := t.TempDir()
+	nextFiles := map[string]string{"a": "a"}
+	nextDir := filepath.Join(tmp, "next")
+	writeFiles(t, nextDir, nextFiles)
+	next := makeManifest(t, nextDir, nextFiles)
+
+	if err := Apply(filepath.Join(tmp, "installed"), filepath.Join(tmp, "staging"), next); !errors.Is(err, ErrFailedToCopyFile) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrFailedToCopyFile, err)
+	}
+}
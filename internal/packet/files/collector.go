@@ -2,7 +2,11 @@ package files
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -19,21 +23,159 @@ var (
 	ErrInternalError = errors.New("syscal failed")
 	ErrInvalidPath   = errors.New("invalid path")
 	ErrNoTargets     = errors.New("no input targets")
+	ErrInvalidDest   = errors.New("file is outside of strip_prefix of its target")
+	ErrDestConflict  = errors.New("several files are placed to the same path in the package")
+	ErrOutsideRoot   = errors.New("target is outside of its root")
+
+	ErrFailedToCollect       = errors.New("failed to collect files")
+	ErrFailedToReadDirectory = errors.New("failed to read directory")
+	ErrFailedToReadFile      = errors.New("failed to read file information")
+	ErrTooFewFiles           = errors.New("target matched too few files")
 )
 
+// CollectError lists every problem found while collecting files, so a package is never silently incomplete.
+// errors.Is reports ErrFailedToCollect and errors of every problem.
+type CollectError struct {
+	Problems []error
+}
+
+// newCollectError sorts problems, because workers report them in random order,
+// and drops repeated ones, e.g. an unreadable ignore file is reported by every file it applies to
+func newCollectError(problems []error) *CollectError {
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Error() < problems[j].Error()
+	})
+
+	unique := make([]error, 0, len(problems))
+	for i, problem := range problems {
+		if i == 0 || problem.Error() != problems[i-1].Error() {
+			unique = append(unique, problem)
+		}
+	}
+
+	return &CollectError{Problems: unique}
+}
+
+func (e *CollectError) Error() string {
+	var report strings.Builder
+	report.WriteString(ErrFailedToCollect.Error())
+	report.WriteString(":")
+	for _, problem := range e.Problems {
+		fmt.Fprintf(&report, "\n  %v", problem)
+	}
+
+	return report.String()
+}
+
+func (e *CollectError) Is(target error) bool {
+	if target == ErrFailedToCollect {
+		return true
+	}
+
+	for _, problem := range e.Problems {
+		if errors.Is(problem, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pathError describes a failed file system call without repeating the path
+func pathError(kind error, path string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+
+	return fmt.Errorf("%w: %s: %v", kind, path, err)
+}
+
+// finderInput is a directory matched against the remaining segments of a target pattern
 type finderInput struct {
-	dir     string
-	include string
-	exclude string
+	// target is an index of the target the directory is matched by
+	target int
+	dir    string
+	// pattern segments which are not matched yet, the last one is matched against entry names
+	pattern []string
+	// exclude rules of the target
+	exclude []*Rule
+	// root of the target
+	root *targetRoot
+	// dest places files of the target in the package
+	dest destination
+	// symlinks is the symbolic link policy of the target
+	symlinks string
+	// chain lists real paths of directories the traversal came through, it is kept only if links are followed
+	chain []string
+	opts  CollectOptions
+}
+
+// found is a collected or an excluded path
+type found struct {
+	target int
+	path   string
+	// excludedBy is nil if the path is collected
+	excludedBy *Rule
+	// followed is set for a symbolic link which is stored as the file it points to
+	followed bool
+	err      error
 }
 
-// CollectLocalFileNames ignores symbolic and hard links
+// globstar matches any number of nested directories
+const globstar = "**"
+
+// CollectOptions enable collecting entries other than regular files
+type CollectOptions struct {
+	// Root is a directory files of targets without a base are placed relative to,
+	// it is the common directory of their base directories if it is empty
+	Root string
+	// Symlinks is the symbolic link policy of targets which do not declare their own one:
+	// parser.SymlinksSkip (or empty), parser.SymlinksFollow or parser.SymlinksPreserve
+	Symlinks string
+	// EmptyDirs collects matched directories which have no entries
+	EmptyDirs bool
+	// Excluded is called for every path excluded by a rule of a target or of an ignore file.
+	// Content of an excluded directory is not traversed, so it is reported only once.
+	Excluded func(path string, rule *Rule)
+}
+
+// Collection is a list of files of a package
+type Collection struct {
+	// Root is the package root, targets with their own base are placed relative to the base instead
+	Root string
+	// FileNames are sorted absolute names of collected files
+	FileNames []string
+	// PathsInArchive maps every collected file to its slash separated path in the package
+	PathsInArchive map[string]string
+	// FollowedLinks are collected symbolic links which should be stored as files they point to
+	FollowedLinks map[string]bool
+}
+
+// CollectLocalFileNames ignores symbolic links
 func CollectLocalFileNames(targets []parser.Targets) (string, []string, error) {
+	return CollectLocalFileNamesWithOptions(targets, CollectOptions{})
+}
+
+// CollectLocalFileNamesWithOptions is CollectLocalFileNames which could also collect symbolic links and empty directories
+func CollectLocalFileNamesWithOptions(targets []parser.Targets, opts CollectOptions) (string, []string, error) {
+	collection, err := CollectLocalFiles(targets, opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return collection.Root, collection.FileNames, nil
+}
+
+// CollectLocalFiles is CollectLocalFileNamesWithOptions which also places files to their paths in the package.
+// Files of a target with dest or strip_prefix are placed relative to its strip_prefix (its base directory by default)
+// under dest, files of other targets keep their paths relative to their root: the base of the target or the package root.
+// Targets outside of their root and different files placed to the same path are reported.
+func CollectLocalFiles(targets []parser.Targets, opts CollectOptions) (*Collection, error) {
 	if len(targets) == 0 {
-		return "", nil, ErrNoTargets
+		return nil, ErrNoTargets
 	}
 	res := make([]string, 0)
-	commonRoot := ""
 
 	paths := make([]string, len(targets))
 	for i := range paths {
@@ -42,14 +184,68 @@ func CollectLocalFileNames(targets []parser.Targets) (string, []string, error) {
 
 	err := convertRelativePathsToAbsolutePaths(paths)
 	if err != nil {
-		return "", nil, err
+		return nil, err
+	}
+
+	bases := make([]string, len(paths))
+	patterns := make([][]string, len(paths))
+	for i, path := range paths {
+		bases[i], patterns[i] = splitPattern(path)
+	}
+
+	commonRoot, roots, err := makeRoots(targets, bases, opts.Root)
+	if err != nil {
+		return nil, err
 	}
 
-	// Assuming that to match all files in a directory pattern ends with '/*'
-	commonRoot = filepath.Dir(findCommonPrefix(paths))
+	dests, err := makeDestinations(targets, bases, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []error
+	inputs := make([]finderInput, 0, len(paths))
+	for i := range paths {
+		if !isWithin(roots[i].dir, bases[i]) {
+			problems = append(problems, fmt.Errorf("%w: %s is outside of %s", ErrOutsideRoot, targets[i].Path, roots[i].dir))
+			continue
+		}
+
+		in := finderInput{
+			target:   i,
+			dir:      bases[i],
+			pattern:  patterns[i],
+			exclude:  targetRules(targets[i].Exclude, bases[i]),
+			root:     roots[i],
+			dest:     dests[i],
+			symlinks: opts.Symlinks,
+			opts:     opts,
+		}
+		if targets[i].Symlinks != "" {
+			in.symlinks = targets[i].Symlinks
+		}
+		if in.symlinks == parser.SymlinksFollow {
+			in.chain = []string{realRoot(bases[i])}
+		}
+
+		rule, excludedDir, err := excludedParent(in)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if rule != nil {
+			reportExcluded(opts, excludedDir, rule)
+			continue
+		}
+
+		inputs = append(inputs, in)
+	}
 
-	var resCh = make(chan string)
-	var inputCh = make(chan finderInput)
+	var resCh = make(chan found)
+	var f = &finder{
+		queue: newFinderQueue(inputs),
+		res:   resCh,
+	}
 	var wg sync.WaitGroup
     var workers = len(targets)
     if maxWorkers < workers {
@@ -57,76 +253,521 @@ func CollectLocalFileNames(targets []parser.Targets) (string, []string, error) {
     }
 	// creating worker goroutines
 	for i := 0; i < workers; i++ {
-		go findAllFiles(inputCh, resCh, &wg)
+		go f.findAllFiles(&wg)
 		wg.Add(1)
 	}
 
-	go func() {
-		for i, path := range paths {
-			inputCh <- finderInput{
-				dir:     filepath.Dir(path),
-				include: filepath.Base(path),
-				exclude: targets[i].Exclude,
-			}
-		}
-		close(inputCh)
-	}()
-
 	go func() {
 		wg.Wait()
 		close(resCh)
 	}()
 
-	for path := range resCh {
-		res = append(res, path)
+	// the same file could be matched by several targets or several ways through "**"
+	pathsInArchive := make(map[string]string)
+	followedLinks := make(map[string]bool)
+	placed := make(map[string]string)
+	matched := make(map[targetFile]bool)
+	counts := make([]int, len(targets))
+	for r := range resCh {
+		if r.err != nil {
+			problems = append(problems, r.err)
+			continue
+		}
+
+		if r.excludedBy != nil {
+			reportExcluded(opts, r.path, r.excludedBy)
+			continue
+		}
+
+		if key := (targetFile{r.target, r.path}); !matched[key] {
+			matched[key] = true
+			counts[r.target]++
+		}
+
+		pathInArchive, err := dests[r.target].place(r.path)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+
+		// a link matched by targets with different policies is followed
+		if r.followed {
+			followedLinks[r.path] = true
+		}
+
+		if previous, ok := pathsInArchive[r.path]; ok {
+			if previous != pathInArchive {
+				problems = append(problems, fmt.Errorf("%w: %s is placed to %s and %s", ErrDestConflict, r.path, previous, pathInArchive))
+			}
+			continue
+		}
+
+		if other, ok := placed[pathInArchive]; ok {
+			problems = append(problems, fmt.Errorf("%w: %s and %s are placed to %s", ErrDestConflict, other, r.path, pathInArchive))
+			continue
+		}
+
+		pathsInArchive[r.path] = pathInArchive
+		placed[pathInArchive] = r.path
+		res = append(res, r.path)
+	}
+
+	for i, target := range targets {
+		if minimum := target.MinimumFiles(); counts[i] < minimum {
+			problems = append(problems, fmt.Errorf("%w: %s matched %d, at least %d required", ErrTooFewFiles, target.Path, counts[i], minimum))
+		}
+	}
+
+	if len(problems) != 0 {
+		return nil, newCollectError(problems)
 	}
+	// workers finish in random order
+	sort.Strings(res)
 
-	return commonRoot, res, nil
+	return &Collection{
+		Root:           commonRoot,
+		FileNames:      res,
+		PathsInArchive: pathsInArchive,
+		FollowedLinks:  followedLinks,
+	}, nil
 }
 
-// To implement files collection from subdirectory, I think, that a map should be created
-// which maps directory to exclude and include patterns. Then, on encountering
-// subdirectory in directory, check whether such directory is mapped to the patterns.
-// If yes, it means that this directory is already in a queue.
-// If no, add include and exclude (if it has wildcard matching) paths of the current directory to the queue.
-func findAllFiles(input <-chan finderInput, res chan<- string, wg *sync.WaitGroup) {
-	defer wg.Done()
+// targetFile is a file matched by a target
+type targetFile struct {
+	target int
+	path   string
+}
+
+// destination places files of a target in the package
+type destination struct {
+	// from is a local directory paths of files are relative to
+	from string
+	// to is a slash separated directory in the package
+	to string
+}
 
-	for in := range input {
-		dir, include, exclude := in.dir, in.include, in.exclude
-		entries, err := os.ReadDir(dir)
+// targetRoot is a directory files of targets are placed relative to.
+// Ignore files are read from it and its subdirectories, followed links should not point outside of it.
+type targetRoot struct {
+	dir     string
+	real    string
+	matcher *ignoreMatcher
+}
+
+func newTargetRoot(dir string) *targetRoot {
+	return &targetRoot{
+		dir:     dir,
+		real:    realRoot(dir),
+		matcher: newIgnoreMatcher(dir),
+	}
+}
+
+// makeRoots returns the package root and the root of every target.
+// Targets with the same root share it, so ignore files are read once.
+func makeRoots(targets []parser.Targets, bases []string, declared string) (string, []*targetRoot, error) {
+	dirs := make([]string, len(targets))
+	unbased := make([]string, 0, len(targets))
+	for i, target := range targets {
+		if target.Base == "" {
+			unbased = append(unbased, bases[i])
+			continue
+		}
+
+		dir, err := absolutePath(target.Base)
 		if err != nil {
-			return
+			return "", nil, err
 		}
+		dirs[i] = dir
+	}
 
-		for _, entry := range entries {
-			if entry.IsDir() {
-				continue
-			}
+	var packageRoot string
+	switch {
+	case declared != "":
+		dir, err := absolutePath(declared)
+		if err != nil {
+			return "", nil, err
+		}
+		packageRoot = dir
+	case len(unbased) != 0:
+		packageRoot = findCommonDirectory(unbased)
+	default:
+		// every target has its own base, the package root is not used to place files
+		packageRoot = findCommonDirectory(dirs)
+	}
 
-			filePath := filepath.Join(dir, entry.Name())
-			info, err := os.Lstat(filePath)
+	shared := make(map[string]*targetRoot)
+	roots := make([]*targetRoot, len(targets))
+	for i, dir := range dirs {
+		if dir == "" {
+			dir = packageRoot
+		}
+		if shared[dir] == nil {
+			shared[dir] = newTargetRoot(dir)
+		}
+		roots[i] = shared[dir]
+	}
+
+	return packageRoot, roots, nil
+}
+
+func makeDestinations(targets []parser.Targets, bases []string, roots []*targetRoot) ([]destination, error) {
+	dests := make([]destination, len(targets))
+	for i, target := range targets {
+		from := roots[i].dir
+		switch {
+		case target.StripPrefix != "":
+			prefix, err := absolutePath(target.StripPrefix)
 			if err != nil {
-			    continue	
+				return nil, err
 			}
+			from = prefix
+		case target.Dest != "" && target.Base == "":
+			from = bases[i]
+		}
 
-			if !info.Mode().IsRegular() {
-				continue
+		dests[i] = destination{
+			from: from,
+			to:   path.Clean("/" + target.Dest)[1:],
+		}
+	}
+
+	return dests, nil
+}
+
+// place returns the slash separated path of fileName in the package
+func (d destination) place(fileName string) (string, error) {
+	rel, err := filepath.Rel(d.from, fileName)
+	if err != nil || rel == "." || !isWithin(d.from, fileName) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidDest, fileName)
+	}
+
+	return path.Join(d.to, filepath.ToSlash(rel)), nil
+}
+
+// excludedParent checks directories between the root and the base directory of a target,
+// because files of an excluded directory could not be included back
+func excludedParent(in finderInput) (*Rule, string, error) {
+	rel, err := filepath.Rel(in.root.dir, in.dir)
+	if err != nil || rel == "." {
+		return nil, "", nil
+	}
+
+	dir := in.root.dir
+	for _, segment := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, segment)
+		rule, err := in.root.matcher.excludedBy(dir, true, in.exclude)
+		if err != nil || rule != nil {
+			return rule, dir, err
+		}
+	}
+
+	return nil, "", nil
+}
+
+func reportExcluded(opts CollectOptions, path string, rule *Rule) {
+	if opts.Excluded != nil {
+		opts.Excluded(path, rule)
+	}
+}
+
+// splitPattern returns the longest leading directory of path without wildcards
+// and the rest of path split into segments. The last segment is always a pattern of entry names.
+func splitPattern(path string) (string, []string) {
+	segments := strings.Split(filepath.ToSlash(path), "/")
+	static := len(segments) - 1
+	for i, segment := range segments[:len(segments)-1] {
+		if hasMeta(segment) {
+			static = i
+			break
+		}
+	}
+
+	base := filepath.FromSlash(strings.Join(segments[:static], "/"))
+	if base == "" {
+		base = string(filepath.Separator)
+	}
+
+	pattern := segments[static:]
+	// "dir/**" matches every file under dir
+	if pattern[len(pattern)-1] == globstar {
+		pattern = append(pattern, "*")
+	}
+
+	return base, pattern
+}
+
+func hasMeta(segment string) bool {
+	return strings.ContainsAny(segment, "*?[\\")
+}
+
+// finder is shared by workers collecting files
+type finder struct {
+	queue *finderQueue
+	res   chan<- found
+}
+
+// findAllFiles takes directories from the queue until every directory is processed.
+// Subdirectories matched by the pattern are added to the queue, so nested directories are traversed by the same workers.
+func (f *finder) findAllFiles(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		in, ok := f.queue.pop()
+		if !ok {
+			return
+		}
+
+		f.findFiles(in)
+		f.queue.done()
+	}
+}
+
+// findFiles reports every problem to res and goes on, so all of them are reported at once
+func (f *finder) findFiles(in finderInput) {
+	entries, err := os.ReadDir(in.dir)
+	if err != nil {
+		f.res <- found{target: in.target, err: pathError(ErrFailedToReadDirectory, in.dir, err)}
+		return
+	}
+
+	// descend pushes a subdirectory to the queue
+	descend := func(entry fs.DirEntry, pattern []string, recursive bool) {
+		dir, chain, ok := f.subdirectory(in, entry, recursive)
+		if !ok {
+			return
+		}
+
+		next := in.with(dir, pattern)
+		next.chain = chain
+		f.queue.push(next)
+	}
+
+	if in.pattern[0] == globstar {
+		// "**" matches no directories too
+		f.queue.push(in.with(in.dir, in.pattern[1:]))
+		for _, entry := range entries {
+			descend(entry, in.pattern, true)
+		}
+		return
+	}
+
+	if len(in.pattern) > 1 {
+		for _, entry := range entries {
+			if matched, err := filepath.Match(in.pattern[0], entry.Name()); err == nil && matched {
+				descend(entry, in.pattern[1:], false)
 			}
+		}
+		return
+	}
 
-			included, err := filepath.Match(include, info.Name())
-			if err != nil || !included {
+	include := in.pattern[0]
+	for _, entry := range entries {
+		included, err := filepath.Match(include, entry.Name())
+		if err != nil || !included || entry.Name() == IgnoreFileName {
+			continue
+		}
+
+		filePath := filepath.Join(in.dir, entry.Name())
+		info, err := os.Lstat(filePath)
+		if err != nil {
+			f.res <- found{target: in.target, err: pathError(ErrFailedToReadFile, filePath, err)}
+			continue
+		}
+
+		link := info.Mode()&fs.ModeSymlink != 0
+		followed := link && in.symlinks == parser.SymlinksFollow
+		switch {
+		case link && !followed && in.symlinks != parser.SymlinksPreserve:
+			continue
+		case followed:
+			info, err = os.Stat(filePath)
+			if err != nil {
+				f.res <- found{target: in.target, err: pathError(ErrFailedToReadFile, filePath, err)}
 				continue
 			}
+		}
 
-			excluded, err := filepath.Match(exclude, info.Name())
-			if err != nil || excluded {
+		if info.IsDir() && !(in.opts.EmptyDirs && isEmptyDir(filePath)) {
+			continue
+		}
+
+		if !isCollected(info.Mode(), in.opts) {
+			continue
+		}
+
+		rule, err := in.root.matcher.excludedBy(filePath, info.IsDir(), in.exclude)
+		if err != nil {
+			f.res <- found{target: in.target, err: err}
+			continue
+		}
+
+		// excluded links are not checked, they are not in the package
+		if link && rule == nil {
+			if followed {
+				_, _, err = in.root.followLink(filePath)
+			} else {
+				err = checkPreservedLink(filePath, in.dest)
+			}
+			if err != nil {
+				f.res <- found{target: in.target, err: err}
 				continue
 			}
+		}
+
+		f.res <- found{target: in.target, path: filePath, excludedBy: rule, followed: followed}
+	}
+}
 
-			res <- filePath
+// subdirectory returns a directory entry the traversal could descend into with the chain of real paths.
+// Links to directories are descended into only if they are followed,
+// recursive traversal through "**" refuses links to directories it came through.
+func (f *finder) subdirectory(in finderInput, entry fs.DirEntry, recursive bool) (string, []string, bool) {
+	dir := filepath.Join(in.dir, entry.Name())
+	if entry.IsDir() {
+		if f.excluded(in, dir) {
+			return "", nil, false
+		}
+		if in.chain == nil {
+			return dir, nil, true
 		}
+		return dir, in.chained(filepath.Join(in.chain[len(in.chain)-1], entry.Name())), true
+	}
+
+	if entry.Type()&fs.ModeSymlink == 0 || in.symlinks != parser.SymlinksFollow {
+		return "", nil, false
+	}
+
+	// links to files are not followed here, so they are checked only if they are collected
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", nil, false
+	}
+
+	// excluded links are not resolved, so they could point anywhere
+	if f.excluded(in, dir) {
+		return "", nil, false
+	}
+
+	real, _, err := in.root.followLink(dir)
+	if err != nil {
+		f.res <- found{target: in.target, err: err}
+		return "", nil, false
+	}
+
+	if recursive && isLoop(in.chain, real) {
+		f.res <- found{target: in.target, err: fmt.Errorf("%w: %s -> %s", ErrSymlinkLoop, dir, real)}
+		return "", nil, false
+	}
+
+	return dir, in.chained(real), true
+}
+
+// excluded reports whether a directory is excluded, the traversal does not descend into excluded directories
+func (f *finder) excluded(in finderInput, dir string) bool {
+	rule, err := in.root.matcher.excludedBy(dir, true, in.exclude)
+	switch {
+	case err != nil:
+		f.res <- found{target: in.target, err: err}
+		return true
+	case rule != nil:
+		f.res <- found{target: in.target, path: dir, excludedBy: rule}
+		return true
+	}
+
+	return false
+}
+
+// chained returns a copy of the chain with a directory appended, chains of pushed directories should not share memory
+func (in finderInput) chained(real string) []string {
+	chain := make([]string, 0, len(in.chain)+1)
+	return append(append(chain, in.chain...), real)
+}
+
+func (in finderInput) with(dir string, pattern []string) finderInput {
+	in.dir = dir
+	in.pattern = pattern
+	return in
+}
+
+// finderQueue is an unbounded queue of directories.
+// pop blocks until a directory is available or every pushed directory is processed.
+type finderQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	inputs  []finderInput
+	pending int
+}
+
+func newFinderQueue(inputs []finderInput) *finderQueue {
+	q := &finderQueue{
+		inputs:  inputs,
+		pending: len(inputs),
 	}
+	q.cond = sync.NewCond(&q.mu)
+
+	return q
+}
+
+func (q *finderQueue) push(in finderInput) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.inputs = append(q.inputs, in)
+	q.pending++
+	q.cond.Signal()
+}
+
+func (q *finderQueue) pop() (finderInput, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.inputs) == 0 && q.pending > 0 {
+		q.cond.Wait()
+	}
+
+	if len(q.inputs) == 0 {
+		return finderInput{}, false
+	}
+
+	in := q.inputs[len(q.inputs)-1]
+	q.inputs = q.inputs[:len(q.inputs)-1]
+
+	return in, true
+}
+
+// done is called after a popped directory is processed
+func (q *finderQueue) done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pending--
+	if q.pending == 0 {
+		q.cond.Broadcast()
+	}
+}
+
+// isCollected is called for preserved links and files followed links point to
+func isCollected(mode os.FileMode, opts CollectOptions) bool {
+	switch {
+	case mode.IsRegular():
+		return true
+	case mode&os.ModeSymlink != 0:
+		return true
+	case mode.IsDir():
+		return opts.EmptyDirs
+	}
+
+	return false
+}
+
+func isEmptyDir(dir string) bool {
+	f, err := os.Open(dir)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(1)
+	return err == io.EOF && len(names) == 0
 }
 
 func convertRelativePathsToAbsolutePaths(paths []string) error {
@@ -147,34 +788,31 @@ func convertRelativePathsToAbsolutePaths(paths []string) error {
 	return nil
 }
 
-func findCommonPrefix(strs []string) string {
-	if len(strs) == 0 {
-		return ""
+func absolutePath(path string) (string, error) {
+	paths := []string{path}
+	if err := convertRelativePathsToAbsolutePaths(paths); err != nil {
+		return "", err
 	}
 
-	// Creating new array inorder to not change original strs array
-	var cp = make([]string, len(strs))
-	copy(cp, strs)
-	sort.Slice(cp, func(i, j int) bool {
-		return len(cp[i]) < len(cp[j])
-	})
-
-	var commonStr strings.Builder
-	for i, ch := range []byte(cp[0]) {
-		matched := true
-		for j := 1; j < len(cp); j++ {
-			if ch != cp[j][i] {
-				matched = false
-				break
-			}
-		}
+	return filepath.Clean(paths[0]), nil
+}
 
-		if !matched {
-			break
+// findCommonDirectory returns the deepest directory containing all dirs
+func findCommonDirectory(dirs []string) string {
+	common := strings.Split(filepath.Clean(dirs[0]), string(filepath.Separator))
+	for _, dir := range dirs[1:] {
+		segments := strings.Split(filepath.Clean(dir), string(filepath.Separator))
+		n := 0
+		for n < len(common) && n < len(segments) && common[n] == segments[n] {
+			n++
 		}
+		common = common[:n]
+	}
 
-		commonStr.WriteByte(ch)
+	root := strings.Join(common, string(filepath.Separator))
+	if root == "" {
+		return string(filepath.Separator)
 	}
 
-	return commonStr.String()
+	return root
 }
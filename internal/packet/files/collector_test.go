@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/Elementary1092/pm/internal/packet/parser"
@@ -95,7 +96,7 @@ func TestCollectLocalFileNames_WithAbsoluteFilePathAndWithExclude(t *testing.T)
     fileName := filepath.Join(tmp, "file")
     target := parser.Targets{
         Path: fileName,
-        Exclude: "file",
+        Exclude: parser.Patterns{"file"},
     }
     
     if err := os.WriteFile(fileName, []byte("some text"), 0644); err != nil {
@@ -130,7 +131,7 @@ func TestCollectLocalFileNames_WithRelativePathAndWithExclude(t *testing.T) {
     fileName := filepath.Join(".", "file")
     target := parser.Targets{
         Path: fileName,
-        Exclude: "file",
+        Exclude: parser.Patterns{"file"},
     }
     
     if err := os.WriteFile(fileName, []byte("some text"), 0644); err != nil {
@@ -151,3 +152,325 @@ func TestCollectLocalFileNames_WithRelativePathAndWithExclude(t *testing.T) {
     }
 }
 
+
+func TestCollectLocalFileNamesWithOptions_SymlinksAndEmptyDirs(t *testing.T) {
+    tmp := t.TempDir()
+
+    fileName := filepath.Join(tmp, "file")
+    if err := os.WriteFile(fileName, []byte("some text"), 0644); err != nil {
+        t.Fatal("Failed to create test file:", err)
+    }
+
+    linkName := filepath.Join(tmp, "link")
+    if err := os.Symlink("file", linkName); err != nil {
+        t.Fatal("Failed to create symbolic link:", err)
+    }
+
+    emptyDir := filepath.Join(tmp, "empty")
+    if err := os.Mkdir(emptyDir, os.ModePerm); err != nil {
+        t.Fatal("Failed to create directory:", err)
+    }
+
+    fullDir := filepath.Join(tmp, "full")
+    if err := os.Mkdir(fullDir, os.ModePerm); err != nil {
+        t.Fatal("Failed to create directory:", err)
+    }
+    if err := os.WriteFile(filepath.Join(fullDir, "file"), []byte("some text"), 0644); err != nil {
+        t.Fatal("Failed to create test file:", err)
+    }
+
+    targets := []parser.Targets{{Path: filepath.Join(tmp, "*")}}
+
+    _, fileNames, err := CollectLocalFileNames(targets)
+    if err != nil {
+        t.Fatal("Failed to collect files:", err)
+    }
+
+    if len(fileNames) != 1 {
+        t.Fatalf("Only regular files are expected by default: %v", fileNames)
+    }
+
+    _, fileNames, err = CollectLocalFileNamesWithOptions(targets, CollectOptions{Symlinks: parser.SymlinksPreserve, EmptyDirs: true})
+    if err != nil {
+        t.Fatal("Failed to collect files:", err)
+    }
+
+    collected := make(map[string]bool, len(fileNames))
+    for _, f := range fileNames {
+        collected[f] = true
+    }
+
+    if len(fileNames) != 3 || !collected[fileName] || !collected[linkName] || !collected[emptyDir] {
+        t.Fatalf("Invalid collected files: %v", fileNames)
+    }
+}
+
+func writeTestFiles(t *testing.T, root string, names ...string) {
+    for _, name := range names {
+        fileName := filepath.Join(root, filepath.FromSlash(name))
+        if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+            t.Fatal("Failed to create directory:", err)
+        }
+        if err := os.WriteFile(fileName, []byte("some text"), 0644); err != nil {
+            t.Fatal("Failed to create test file:", err)
+        }
+    }
+}
+
+func assertCollected(t *testing.T, root string, fileNames []string, expected ...string) {
+    if len(fileNames) != len(expected) {
+        t.Fatalf("Invalid collected files: expected=%v; got=%v", expected, fileNames)
+    }
+
+    for i, name := range expected {
+        if fileNames[i] != filepath.Join(root, filepath.FromSlash(name)) {
+            t.Fatalf("Invalid collected files: expected=%v; got=%v", expected, fileNames)
+        }
+    }
+}
+
+func assertPlaced(t *testing.T, collection *Collection, expected map[string]string) {
+    if len(collection.PathsInArchive) != len(expected) {
+        t.Fatalf("Invalid paths in archive: expected=%v; got=%v", expected, collection.PathsInArchive)
+    }
+
+    for fileName, pathInArchive := range expected {
+        if collection.PathsInArchive[fileName] != pathInArchive {
+            t.Fatalf("Invalid paths in archive: expected=%v; got=%v", expected, collection.PathsInArchive)
+        }
+    }
+}
+
+func TestCollectLocalFileNames_Globstar(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "assets/a.png", "assets/x/b.png", "assets/x/y/c.png", "assets/x/d.txt", "other/e.png")
+
+    commonRoot, fileNames, err := CollectLocalFileNames([]parser.Targets{{Path: filepath.Join(tmp, "assets", "**", "*.png")}})
+    if err != nil {
+        t.Fatal("Failed to collect files:", err)
+    }
+
+    assets := filepath.Join(tmp, "assets")
+    if commonRoot != assets {
+        t.Fatalf("Invalid common root: expected='%s'; got='%s'", assets, commonRoot)
+    }
+
+    assertCollected(t, tmp, fileNames, "assets/a.png", "assets/x/b.png", "assets/x/y/c.png")
+}
+
+func TestCollectLocalFileNames_TrailingGlobstar(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "dir/a", "dir/x/b", "dir/x/y/c")
+
+    _, fileNames, err := CollectLocalFileNames([]parser.Targets{{Path: filepath.Join(tmp, "dir", "**"), Exclude: parser.Patterns{"b"}}})
+    if err != nil {
+        t.Fatal("Failed to collect files:", err)
+    }
+
+    assertCollected(t, tmp, fileNames, "dir/a", "dir/x/y/c")
+}
+
+func TestCollectLocalFileNames_WildcardDirectory(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "a/sub/1", "b/sub/2", "b/other/3", "c/sub/deep/4")
+
+    commonRoot, fileNames, err := CollectLocalFileNames([]parser.Targets{{Path: filepath.Join(tmp, "*", "sub", "*")}})
+    if err != nil {
+        t.Fatal("Failed to collect files:", err)
+    }
+
+    if commonRoot != tmp {
+        t.Fatalf("Invalid common root: expected='%s'; got='%s'", tmp, commonRoot)
+    }
+
+    assertCollected(t, tmp, fileNames, "a/sub/1", "b/sub/2")
+}
+
+func TestCollectLocalFileNames_OverlappingTargets(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "a/1", "a/b/2", "c/3")
+
+    commonRoot, fileNames, err := CollectLocalFileNames([]parser.Targets{
+        {Path: filepath.Join(tmp, "a", "**", "*")},
+        {Path: filepath.Join(tmp, "a", "b", "*")},
+        {Path: filepath.Join(tmp, "c", "*")},
+    })
+    if err != nil {
+        t.Fatal("Failed to collect files:", err)
+    }
+
+    if commonRoot != tmp {
+        t.Fatalf("Invalid common root: expected='%s'; got='%s'", tmp, commonRoot)
+    }
+
+    assertCollected(t, tmp, fileNames, "a/1", "a/b/2", "c/3")
+}
+
+func TestCollectLocalFiles_Destinations(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "app/main.txt", "shared/config/a.conf", "build/out/bin/tool")
+
+    collection, err := CollectLocalFiles([]parser.Targets{
+        {Path: filepath.Join(tmp, "app", "*")},
+        {Path: filepath.Join(tmp, "shared", "config", "*"), Dest: "etc/config"},
+        {Path: filepath.Join(tmp, "build", "**"), StripPrefix: filepath.Join(tmp, "build", "out")},
+    }, CollectOptions{})
+    if err != nil {
+        t.Fatal("Failed to collect files:", err)
+    }
+
+    expected := map[string]string{
+        filepath.Join(tmp, "app", "main.txt"):             "app/main.txt",
+        filepath.Join(tmp, "shared", "config", "a.conf"):  "etc/config/a.conf",
+        filepath.Join(tmp, "build", "out", "bin", "tool"): "bin/tool",
+    }
+    assertPlaced(t, collection, expected)
+}
+
+func TestCollectLocalFiles_ConflictingDestinations(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "a/config.json", "b/config.json")
+
+    _, err := CollectLocalFiles([]parser.Targets{
+        {Path: filepath.Join(tmp, "a", "*"), Dest: "etc"},
+        {Path: filepath.Join(tmp, "b", "*"), Dest: "etc"},
+    }, CollectOptions{})
+    if !errors.Is(err, ErrDestConflict) {
+        t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrDestConflict, err)
+    }
+}
+
+func TestCollectLocalFiles_SameFileToSeveralDestinations(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "a/config.json")
+
+    _, err := CollectLocalFiles([]parser.Targets{
+        {Path: filepath.Join(tmp, "a", "*")},
+        {Path: filepath.Join(tmp, "a", "*"), Dest: "etc"},
+    }, CollectOptions{})
+    if !errors.Is(err, ErrDestConflict) {
+        t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrDestConflict, err)
+    }
+}
+
+func TestCollectLocalFiles_FileOutsideStripPrefix(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "a/config.json")
+
+    _, err := CollectLocalFiles([]parser.Targets{
+        {Path: filepath.Join(tmp, "a", "*"), StripPrefix: filepath.Join(tmp, "b")},
+    }, CollectOptions{})
+    if !errors.Is(err, ErrInvalidDest) {
+        t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidDest, err)
+    }
+}
+
+func TestCollectLocalFiles_Roots(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "etc/myapp/app.conf", "etc/myapp/conf.d/log.conf", "project/build/tool", "project/build/debug.log")
+    if err := os.WriteFile(filepath.Join(tmp, "project", IgnoreFileName), []byte("*.log\n"), 0644); err != nil {
+        t.Fatal("Failed to create ignore file:", err)
+    }
+    // ignore files above the root are not applied
+    if err := os.WriteFile(filepath.Join(tmp, IgnoreFileName), []byte("tool\n"), 0644); err != nil {
+        t.Fatal("Failed to create ignore file:", err)
+    }
+
+    project := filepath.Join(tmp, "project")
+    collection, err := CollectLocalFiles([]parser.Targets{
+        {Path: filepath.Join(tmp, "etc", "myapp", "*.conf"), Base: filepath.Join(tmp, "etc")},
+        {Path: filepath.Join(tmp, "etc", "myapp", "conf.d", "*"), Base: filepath.Join(tmp, "etc", "myapp"), Dest: "config"},
+        {Path: filepath.Join(project, "build", "*")},
+    }, CollectOptions{Root: project})
+    if err != nil {
+        t.Fatal("Failed to collect files:", err)
+    }
+
+    if collection.Root != project {
+        t.Fatalf("Invalid root: expected='%s'; got='%s'", project, collection.Root)
+    }
+    assertPlaced(t, collection, map[string]string{
+        filepath.Join(tmp, "etc", "myapp", "app.conf"):           "myapp/app.conf",
+        filepath.Join(tmp, "etc", "myapp", "conf.d", "log.conf"): "config/conf.d/log.conf",
+        filepath.Join(project, "build", "tool"):                  "build/tool",
+    })
+}
+
+func TestCollectLocalFiles_TargetOutsideRoot(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "etc/app.conf", "project/a/file", "project/b/file")
+
+    project := filepath.Join(tmp, "project")
+    _, err := CollectLocalFiles([]parser.Targets{
+        {Path: filepath.Join(tmp, "etc", "*")},
+        {Path: filepath.Join(project, "a", "*"), Base: filepath.Join(project, "b")},
+        {Path: filepath.Join(project, "b", "*")},
+    }, CollectOptions{Root: project})
+    if !errors.Is(err, ErrOutsideRoot) {
+        t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrOutsideRoot, err)
+    }
+
+    var collectErr *CollectError
+    if !errors.As(err, &collectErr) || len(collectErr.Problems) != 2 {
+        t.Fatalf("Every target outside of its root should be reported: %v", err)
+    }
+}
+
+func TestCollectLocalFileNames_MissingDirectories(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "a/file")
+
+    _, _, err := CollectLocalFileNames([]parser.Targets{
+        {Path: filepath.Join(tmp, "a", "*")},
+        {Path: filepath.Join(tmp, "typo", "*")},
+        {Path: filepath.Join(tmp, "other", "**", "*.txt")},
+    })
+    if !errors.Is(err, ErrFailedToCollect) || !errors.Is(err, ErrFailedToReadDirectory) {
+        t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrFailedToReadDirectory, err)
+    }
+
+    var collectErr *CollectError
+    if !errors.As(err, &collectErr) || len(collectErr.Problems) != 2 {
+        t.Fatalf("Every missing directory should be reported: %v", err)
+    }
+
+    for _, dir := range []string{"other", "typo"} {
+        if !strings.Contains(err.Error(), filepath.Join(tmp, dir)) {
+            t.Fatalf("Directory '%s' is not reported: %v", dir, err)
+        }
+    }
+}
+
+func TestCollectLocalFileNames_RequiredTarget(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "a/file.txt", "b/file.log")
+
+    targets := []parser.Targets{
+        {Path: filepath.Join(tmp, "a", "*.txt"), Required: true},
+        {Path: filepath.Join(tmp, "b", "*.txt")},
+    }
+    if _, _, err := CollectLocalFileNames(targets); err != nil {
+        t.Fatal("Target which is not required could match nothing:", err)
+    }
+
+    targets[1].Required = true
+    _, _, err := CollectLocalFileNames(targets)
+    if !errors.Is(err, ErrTooFewFiles) || !strings.Contains(err.Error(), targets[1].Path) {
+        t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrTooFewFiles, err)
+    }
+}
+
+func TestCollectLocalFileNames_MinFiles(t *testing.T) {
+    tmp := t.TempDir()
+    writeTestFiles(t, tmp, "a/1.txt", "a/x/2.txt", "a/skip.txt")
+
+    targets := []parser.Targets{{Path: filepath.Join(tmp, "a", "**"), Exclude: parser.Patterns{"skip.txt"}, MinFiles: 2}}
+    if _, _, err := CollectLocalFileNames(targets); err != nil {
+        t.Fatal("Failed to collect files:", err)
+    }
+
+    targets[0].MinFiles = 3
+    if _, _, err := CollectLocalFileNames(targets); !errors.Is(err, ErrTooFewFiles) {
+        t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrTooFewFiles, err)
+    }
+}
@@ -0,0 +1,245 @@
+package files
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IgnoreFileName is a file with exclude patterns of its directory and all of its subdirectories.
+// Ignore files are never collected.
+const IgnoreFileName = ".pmignore"
+
+var (
+	ErrFailedToReadIgnoreFile = errors.New("failed to read ignore file")
+)
+
+// Rule is an exclude pattern with .gitignore semantics:
+// a pattern without a slash matches names at any depth, other patterns are relative to the directory of the rule,
+// "**" matches any number of directories, trailing slash matches only directories and "!" includes matched files back.
+type Rule struct {
+	// Pattern is the pattern as it is written
+	Pattern string
+	// Source is a file name with a line number or a target the rule is declared in
+	Source  string
+	Negate  bool
+	dirOnly bool
+	// base is a directory the pattern is relative to
+	base     string
+	segments []string
+}
+
+func (r *Rule) String() string {
+	return fmt.Sprintf("%s: %s", r.Source, r.Pattern)
+}
+
+// newRule returns nil for blank lines and comments
+func newRule(pattern string, base string, source string) *Rule {
+	rule := &Rule{
+		Pattern: pattern,
+		Source:  source,
+		base:    base,
+	}
+
+	pattern = strings.TrimRight(pattern, " \t\r")
+	switch {
+	case pattern == "" || strings.HasPrefix(pattern, "#"):
+		return nil
+	case strings.HasPrefix(pattern, "!"):
+		rule.Negate = true
+		pattern = pattern[1:]
+	case strings.HasPrefix(pattern, `\#`) || strings.HasPrefix(pattern, `\!`):
+		pattern = pattern[1:]
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return nil
+	}
+
+	rule.segments = strings.Split(pattern, "/")
+	if !anchored {
+		rule.segments = append([]string{globstar}, rule.segments...)
+	}
+
+	return rule
+}
+
+func (r *Rule) matches(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	rel, err := filepath.Rel(r.base, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	return matchSegments(r.segments, strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+func matchSegments(pattern []string, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == globstar {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(path) == 0 {
+			return false
+		}
+
+		matched, err := filepath.Match(pattern[0], path[0])
+		if err != nil || !matched {
+			return false
+		}
+
+		pattern, path = pattern[1:], path[1:]
+	}
+
+	return len(path) == 0
+}
+
+// decide returns the last rule matching path unless it is negated: the last matching rule wins
+func decide(rules []*Rule, path string, isDir bool) *Rule {
+	var excluding *Rule
+	for _, rule := range rules {
+		if !rule.matches(path, isDir) {
+			continue
+		}
+
+		excluding = rule
+		if rule.Negate {
+			excluding = nil
+		}
+	}
+
+	return excluding
+}
+
+// targetRules returns exclude patterns of a target relative to its base directory
+func targetRules(patterns []string, base string) []*Rule {
+	rules := make([]*Rule, 0, len(patterns))
+	for _, pattern := range patterns {
+		if rule := newRule(pattern, base, "exclude"); rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+// ignoreMatcher applies ignore files of root and its subdirectories.
+// Ignore files are read once and shared by all workers.
+type ignoreMatcher struct {
+	root string
+	mu   sync.Mutex
+	// rules of ignore files of a directory and all of its parents up to root
+	rules map[string][]*Rule
+}
+
+func newIgnoreMatcher(root string) *ignoreMatcher {
+	return &ignoreMatcher{
+		root:  root,
+		rules: make(map[string][]*Rule),
+	}
+}
+
+// excludedBy returns the rule excluding path or nil if path is not excluded.
+// Rules of ignore files go first, so exclude patterns of a target could include files back.
+// Parent directories of path should be checked by the caller:
+// a file could not be included back if its directory is excluded.
+func (m *ignoreMatcher) excludedBy(path string, isDir bool, extra []*Rule) (*Rule, error) {
+	rules, err := m.rulesOf(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(extra) != 0 {
+		rules = append(append(make([]*Rule, 0, len(rules)+len(extra)), rules...), extra...)
+	}
+
+	return decide(rules, path, isDir), nil
+}
+
+func (m *ignoreMatcher) rulesOf(dir string) ([]*Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.loadRules(dir)
+}
+
+// loadRules is called with the mutex locked
+func (m *ignoreMatcher) loadRules(dir string) ([]*Rule, error) {
+	if rules, ok := m.rules[dir]; ok {
+		return rules, nil
+	}
+
+	var inherited []*Rule
+	if dir != m.root && isWithin(m.root, dir) {
+		var err error
+		if inherited, err = m.loadRules(filepath.Dir(dir)); err != nil {
+			return nil, err
+		}
+	}
+
+	own, err := readIgnoreFile(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := append(append(make([]*Rule, 0, len(inherited)+len(own)), inherited...), own...)
+	m.rules[dir] = rules
+
+	return rules, nil
+}
+
+func readIgnoreFile(dir string) ([]*Rule, error) {
+	fileName := filepath.Join(dir, IgnoreFileName)
+	f, err := os.Open(fileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFailedToReadIgnoreFile, fileName)
+	}
+	defer f.Close()
+
+	rules := make([]*Rule, 0)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if rule := newRule(scanner.Text(), dir, fmt.Sprintf("%s:%d", fileName, line)); rule != nil {
+			rules = append(rules, rule)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrFailedToReadIgnoreFile, fileName)
+	}
+
+	return rules, nil
+}
+
+func isWithin(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,171 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Elementary1092/pm/internal/packet/parser"
+)
+
+func writeIgnoreFile(t *testing.T, dir string, lines ...string) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal("Failed to create directory:", err)
+	}
+
+	content := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFileName), []byte(content), 0644); err != nil {
+		t.Fatal("Failed to create ignore file:", err)
+	}
+}
+
+func TestRule_Matches(t *testing.T) {
+	base := filepath.FromSlash("/root")
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		matches bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "x/y/a.log", false, true},
+		{"/a.log", "x/a.log", false, false},
+		{"x/*.log", "x/a.log", false, true},
+		{"x/*.log", "y/x/a.log", false, false},
+		{"x/**/a.log", "x/a.log", false, true},
+		{"x/**/a.log", "x/y/z/a.log", false, true},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{`\#notes`, "#notes", false, true},
+		{"a.log", "../a.log", false, false},
+	}
+
+	for _, test := range tests {
+		rule := newRule(test.pattern, base, "test")
+		path := filepath.Join(base, filepath.FromSlash(test.path))
+		if matched := rule.matches(path, test.isDir); matched != test.matches {
+			t.Errorf("Pattern '%s' matching '%s': expected=%v; got=%v", test.pattern, test.path, test.matches, matched)
+		}
+	}
+}
+
+func TestNewRule_CommentsAndBlankLines(t *testing.T) {
+	for _, pattern := range []string{"", "   ", "# comment", "!", "/"} {
+		if rule := newRule(pattern, "/root", "test"); rule != nil {
+			t.Errorf("Pattern '%s' should be skipped", pattern)
+		}
+	}
+}
+
+func TestCollectLocalFileNames_ExcludeListWithNegation(t *testing.T) {
+	tmp := t.TempDir()
+	writeTestFiles(t, tmp, "a.log", "b.log", "keep.log", "c.txt")
+
+	_, fileNames, err := CollectLocalFileNames([]parser.Targets{{
+		Path:    filepath.Join(tmp, "*"),
+		Exclude: parser.Patterns{"*.log", "!keep.log"},
+	}})
+	if err != nil {
+		t.Fatal("Failed to collect files:", err)
+	}
+
+	assertCollected(t, tmp, fileNames, "c.txt", "keep.log")
+}
+
+func TestCollectLocalFileNames_IgnoreFiles(t *testing.T) {
+	tmp := t.TempDir()
+	writeTestFiles(t, tmp, "a.txt", "debug.log", "build/out.bin", "src/b.txt", "src/c.tmp", "src/gen/d.txt", "src/gen/keep.tmp")
+	writeIgnoreFile(t, tmp, "# build output", "build/", "*.log", "*.tmp")
+	writeIgnoreFile(t, filepath.Join(tmp, "src"), "/gen/d.txt", "!keep.tmp")
+
+	_, fileNames, err := CollectLocalFileNames([]parser.Targets{{Path: filepath.Join(tmp, "**")}})
+	if err != nil {
+		t.Fatal("Failed to collect files:", err)
+	}
+
+	assertCollected(t, tmp, fileNames, "a.txt", "src/b.txt", "src/gen/keep.tmp")
+}
+
+func TestCollectLocalFileNames_TargetExcludeOverridesIgnoreFile(t *testing.T) {
+	tmp := t.TempDir()
+	writeTestFiles(t, tmp, "a.txt", "b.log")
+	writeIgnoreFile(t, tmp, "*.log")
+
+	_, fileNames, err := CollectLocalFileNames([]parser.Targets{{
+		Path:    filepath.Join(tmp, "*"),
+		Exclude: parser.Patterns{"!b.log"},
+	}})
+	if err != nil {
+		t.Fatal("Failed to collect files:", err)
+	}
+
+	assertCollected(t, tmp, fileNames, "a.txt", "b.log")
+}
+
+func TestCollectLocalFileNames_ExcludedDirectoryCouldNotBeIncludedBack(t *testing.T) {
+	tmp := t.TempDir()
+	writeTestFiles(t, tmp, "a.txt", "vendor/b.txt", "vendor/x/c.txt")
+	writeIgnoreFile(t, tmp, "vendor/", "!vendor/b.txt")
+
+	_, fileNames, err := CollectLocalFileNames([]parser.Targets{
+		{Path: filepath.Join(tmp, "*")},
+		{Path: filepath.Join(tmp, "vendor", "x", "*")},
+	})
+	if err != nil {
+		t.Fatal("Failed to collect files:", err)
+	}
+
+	assertCollected(t, tmp, fileNames, "a.txt")
+}
+
+func TestCollectLocalFileNamesWithOptions_ReportsExcluded(t *testing.T) {
+	tmp := t.TempDir()
+	writeTestFiles(t, tmp, "a.txt", "b.log", "cache/c.txt", "cache/d.txt")
+	writeIgnoreFile(t, tmp, "cache/")
+
+	excluded := make(map[string]string)
+	_, _, err := CollectLocalFileNamesWithOptions([]parser.Targets{{
+		Path:    filepath.Join(tmp, "**"),
+		Exclude: parser.Patterns{"*.log"},
+	}}, CollectOptions{
+		Excluded: func(path string, rule *Rule) {
+			excluded[path] = rule.String()
+		},
+	})
+	if err != nil {
+		t.Fatal("Failed to collect files:", err)
+	}
+
+	expected := map[string]string{
+		filepath.Join(tmp, "b.log"): "exclude: *.log",
+		filepath.Join(tmp, "cache"): filepath.Join(tmp, IgnoreFileName) + ":1: cache/",
+	}
+	if len(excluded) != len(expected) {
+		t.Fatalf("Invalid excluded files: expected=%v; got=%v", expected, excluded)
+	}
+	for path, rule := range expected {
+		if excluded[path] != rule {
+			t.Fatalf("Invalid rule excluding '%s': expected='%s'; got='%s'", path, rule, excluded[path])
+		}
+	}
+}
+
+func TestCollectLocalFileNames_UnreadableIgnoreFile(t *testing.T) {
+	tmp := t.TempDir()
+	writeTestFiles(t, tmp, "a.txt", "b.txt")
+	if err := os.Mkdir(filepath.Join(tmp, IgnoreFileName), os.ModePerm); err != nil {
+		t.Fatal("Failed to create directory:", err)
+	}
+
+	_, _, err := CollectLocalFileNames([]parser.Targets{{Path: filepath.Join(tmp, "*")}})
+	if !errors.Is(err, ErrFailedToReadIgnoreFile) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrFailedToReadIgnoreFile, err)
+	}
+
+	var collectErr *CollectError
+	if !errors.As(err, &collectErr) || len(collectErr.Problems) != 1 {
+		t.Fatalf("Ignore file should be reported once: %v", err)
+	}
+}
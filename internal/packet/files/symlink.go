@@ -0,0 +1,85 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrSymlinkOutsideRoot = errors.New("symbolic link points outside of the package root")
+	ErrSymlinkLoop        = errors.New("symbolic link loop")
+)
+
+// followLink resolves a symbolic link which is followed.
+// The real path of the link and information about the file it points to are returned.
+// Links to files outside of the root are refused, because the package would depend on files it does not contain.
+func (r *targetRoot) followLink(link string) (string, fs.FileInfo, error) {
+	real, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		return "", nil, pathError(ErrFailedToReadFile, link, err)
+	}
+
+	if !isWithin(r.real, real) {
+		return "", nil, fmt.Errorf("%w: %s -> %s", ErrSymlinkOutsideRoot, link, real)
+	}
+
+	info, err := os.Stat(real)
+	if err != nil {
+		return "", nil, pathError(ErrFailedToReadFile, link, err)
+	}
+
+	return real, info, nil
+}
+
+// checkPreservedLink refuses links which would point outside of the package after extraction:
+// absolute links and relative links leaving the package from the path the link is placed to by dest
+func checkPreservedLink(link string, dest destination) error {
+	target, err := os.Readlink(link)
+	if err != nil {
+		return pathError(ErrFailedToReadFile, link, err)
+	}
+
+	if filepath.IsAbs(target) {
+		return fmt.Errorf("%w: %s -> %s", ErrSymlinkOutsideRoot, link, target)
+	}
+
+	// a link which could not be placed is reported by the collector
+	pathInArchive, err := dest.place(link)
+	if err != nil {
+		return nil
+	}
+
+	resolved := path.Join(path.Dir(pathInArchive), filepath.ToSlash(target))
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return fmt.Errorf("%w: %s -> %s (placed to %s)", ErrSymlinkOutsideRoot, link, target, pathInArchive)
+	}
+
+	return nil
+}
+
+// isLoop reports whether a directory with the real path real contains one of directories
+// the traversal came through, so "**" would descend into it forever
+func isLoop(chain []string, real string) bool {
+	for _, dir := range chain {
+		if isWithin(real, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// realRoot resolves links in root, so real paths of followed links could be compared with it
+func realRoot(root string) string {
+	real, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return root
+	}
+
+	return real
+}
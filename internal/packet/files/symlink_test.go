@@ -0,0 +1,147 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Elementary1092/pm/internal/packet/parser"
+)
+
+func symlink(t *testing.T, target string, link string) {
+	if err := os.MkdirAll(filepath.Dir(link), os.ModePerm); err != nil {
+		t.Fatal("Failed to create directory:", err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal("Failed to create symbolic link:", err)
+	}
+}
+
+func TestCollectLocalFiles_SymlinkPolicies(t *testing.T) {
+	tmp := t.TempDir()
+	writeTestFiles(t, tmp, "pkg/config/app.conf", "pkg/shared/lib.txt")
+	symlink(t, filepath.Join("config", "app.conf"), filepath.Join(tmp, "pkg", "app.conf"))
+	symlink(t, "shared", filepath.Join(tmp, "pkg", "lib"))
+
+	pkg := filepath.Join(tmp, "pkg")
+	targets := []parser.Targets{{Path: filepath.Join(pkg, "*")}}
+
+	collection, err := CollectLocalFiles(targets, CollectOptions{Symlinks: parser.SymlinksSkip})
+	if err != nil {
+		t.Fatal("Failed to collect files:", err)
+	}
+	assertCollected(t, pkg, collection.FileNames)
+
+	collection, err = CollectLocalFiles(targets, CollectOptions{Symlinks: parser.SymlinksPreserve})
+	if err != nil {
+		t.Fatal("Failed to collect files:", err)
+	}
+	assertCollected(t, pkg, collection.FileNames, "app.conf", "lib")
+	if len(collection.FollowedLinks) != 0 {
+		t.Fatalf("Preserved links should not be followed: %v", collection.FollowedLinks)
+	}
+
+	// links to directories are not collected, their content is
+	targets = []parser.Targets{{Path: filepath.Join(pkg, "**"), Symlinks: parser.SymlinksFollow}}
+	collection, err = CollectLocalFiles(targets, CollectOptions{Symlinks: parser.SymlinksSkip})
+	if err != nil {
+		t.Fatal("Failed to collect files:", err)
+	}
+	assertCollected(t, pkg, collection.FileNames, "app.conf", "config/app.conf", "lib/lib.txt", "shared/lib.txt")
+	if !collection.FollowedLinks[filepath.Join(pkg, "app.conf")] || len(collection.FollowedLinks) != 1 {
+		t.Fatalf("Invalid followed links: %v", collection.FollowedLinks)
+	}
+}
+
+func TestCollectLocalFiles_SymlinkOutsideRoot(t *testing.T) {
+	tmp := t.TempDir()
+	writeTestFiles(t, tmp, "outside/secret", "pkg/file")
+	symlink(t, filepath.Join("..", "outside", "secret"), filepath.Join(tmp, "pkg", "relative"))
+	symlink(t, filepath.Join(tmp, "pkg", "file"), filepath.Join(tmp, "pkg", "absolute"))
+	symlink(t, filepath.Join(tmp, "outside"), filepath.Join(tmp, "pkg", "dir"))
+
+	for _, policy := range []string{parser.SymlinksPreserve, parser.SymlinksFollow} {
+		_, err := CollectLocalFiles([]parser.Targets{{Path: filepath.Join(tmp, "pkg", "**")}}, CollectOptions{Symlinks: policy})
+		if !errors.Is(err, ErrSymlinkOutsideRoot) {
+			t.Fatalf("Unexpected error of %s policy: expected='%v'; got='%v'", policy, ErrSymlinkOutsideRoot, err)
+		}
+
+		var collectErr *CollectError
+		errors.As(err, &collectErr)
+		// an absolute link to a file inside of the root could be followed, but not preserved
+		expected := 2
+		if policy == parser.SymlinksPreserve {
+			expected = 3
+		}
+		if len(collectErr.Problems) != expected {
+			t.Fatalf("Invalid problems of %s policy: %v", policy, err)
+		}
+
+		// excluded links are not checked
+		collection, err := CollectLocalFiles([]parser.Targets{{
+			Path:    filepath.Join(tmp, "pkg", "**"),
+			Exclude: parser.Patterns{"relative", "absolute", "dir"},
+		}}, CollectOptions{Symlinks: policy})
+		if err != nil {
+			t.Fatalf("Failed to collect files of %s policy: %v", policy, err)
+		}
+		assertCollected(t, filepath.Join(tmp, "pkg"), collection.FileNames, "file")
+	}
+}
+
+func TestCollectLocalFiles_RelocatedSymlink(t *testing.T) {
+	tmp := t.TempDir()
+	writeTestFiles(t, tmp, "build/shared.txt", "build/out/lib/lib.txt")
+	// inside of the root locally, but outside of the package after strip_prefix
+	symlink(t, filepath.Join("..", "shared.txt"), filepath.Join(tmp, "build", "out", "shared"))
+	symlink(t, filepath.Join("..", "lib", "lib.txt"), filepath.Join(tmp, "build", "out", "bin", "lib"))
+
+	targets := []parser.Targets{{
+		Path:        filepath.Join(tmp, "build", "out", "**"),
+		StripPrefix: filepath.Join(tmp, "build", "out"),
+	}}
+	_, err := CollectLocalFiles(targets, CollectOptions{Root: tmp, Symlinks: parser.SymlinksPreserve})
+	if !errors.Is(err, ErrSymlinkOutsideRoot) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrSymlinkOutsideRoot, err)
+	}
+
+	var collectErr *CollectError
+	errors.As(err, &collectErr)
+	if len(collectErr.Problems) != 1 {
+		t.Fatalf("Only the link leaving the package should be refused: %v", err)
+	}
+
+	// the link inside of the package is placed under dest with its target
+	targets[0].Dest = "opt"
+	targets[0].Exclude = parser.Patterns{"shared"}
+	collection, err := CollectLocalFiles(targets, CollectOptions{Root: tmp, Symlinks: parser.SymlinksPreserve})
+	if err != nil {
+		t.Fatal("Failed to collect files:", err)
+	}
+	if collection.PathsInArchive[filepath.Join(tmp, "build", "out", "bin", "lib")] != "opt/bin/lib" {
+		t.Fatalf("Invalid paths in archive: %v", collection.PathsInArchive)
+	}
+}
+
+func TestCollectLocalFiles_SymlinkLoop(t *testing.T) {
+	tmp := t.TempDir()
+	writeTestFiles(t, tmp, "a/file", "b/file")
+	symlink(t, filepath.Join("..", "b"), filepath.Join(tmp, "a", "to-b"))
+	symlink(t, filepath.Join("..", "a"), filepath.Join(tmp, "b", "to-a"))
+
+	_, err := CollectLocalFiles([]parser.Targets{{Path: filepath.Join(tmp, "**")}}, CollectOptions{Symlinks: parser.SymlinksFollow})
+	if !errors.Is(err, ErrSymlinkLoop) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrSymlinkLoop, err)
+	}
+
+	// excluded links are not followed
+	collection, err := CollectLocalFiles([]parser.Targets{{
+		Path:    filepath.Join(tmp, "**"),
+		Exclude: parser.Patterns{"to-a"},
+	}}, CollectOptions{Symlinks: parser.SymlinksFollow})
+	if err != nil {
+		t.Fatal("Failed to collect files:", err)
+	}
+	assertCollected(t, tmp, collection.FileNames, "a/file", "a/to-b/file", "b/file")
+}
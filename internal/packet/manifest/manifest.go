@@ -0,0 +1,160 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+)
+
+// Name of the manifest entry in an archive
+const Name = ".pm-manifest.json"
+
+var (
+	ErrInvalidManifestFormat = errors.New("invalid manifest format")
+	ErrFailedToReadManifest  = errors.New("failed to read manifest")
+	ErrFailedToSaveManifest  = errors.New("failed to save manifest")
+	ErrFailedToReadDirectory = errors.New("failed to read installed package directory")
+)
+
+// Entry describes a single file of a package.
+// Path is relative to the package root and always uses forward slashes.
+type Entry struct {
+	Path   string      `json:"path"`
+	Size   int64       `json:"size"`
+	Mode   fs.FileMode `json:"mode"`
+	SHA256 string      `json:"sha256"`
+}
+
+type Manifest struct {
+	// ModesPreserved is set if file modes are restored on extraction,
+	// otherwise modes are not compared by Verify
+	ModesPreserved bool    `json:"modes_preserved,omitempty"`
+	Entries        []Entry `json:"entries"`
+}
+
+func (m *Manifest) Add(entry Entry) {
+	m.Entries = append(m.Entries, entry)
+}
+
+// Sort orders entries by path
+func (m *Manifest) Sort() {
+	sort.Slice(m.Entries, func(i, j int) bool {
+		return m.Entries[i].Path < m.Entries[j].Path
+	})
+}
+
+func Decode(data io.Reader) (*Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(data).Decode(&m); err != nil {
+		return nil, ErrInvalidManifestFormat
+	}
+
+	return &m, nil
+}
+
+func (m *Manifest) Encode(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(m)
+}
+
+func Load(filePath string) (*Manifest, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, ErrFailedToReadManifest
+	}
+	defer f.Close()
+
+	return Decode(f)
+}
+
+func (m *Manifest) Save(filePath string) error {
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return ErrFailedToSaveManifest
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return ErrFailedToSaveManifest
+	}
+	defer f.Close()
+
+	if err := m.Encode(f); err != nil {
+		return ErrFailedToSaveManifest
+	}
+
+	return nil
+}
+
+// Report lists differences between a manifest and an installed package
+type Report struct {
+	Modified []string
+	Missing  []string
+	Extra    []string
+}
+
+func (r *Report) OK() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+// Verify compares files under root with the manifest
+func (m *Manifest) Verify(root string) (*Report, error) {
+	report := &Report{}
+	expected := make(map[string]bool, len(m.Entries))
+	for _, entry := range m.Entries {
+		expected[entry.Path] = true
+
+		filePath := filepath.Join(root, filepath.FromSlash(entry.Path))
+		info, err := os.Lstat(filePath)
+		if err != nil {
+			report.Missing = append(report.Missing, entry.Path)
+			continue
+		}
+
+		if info.Size() != entry.Size || (m.ModesPreserved && info.Mode() != entry.Mode) {
+			report.Modified = append(report.Modified, entry.Path)
+			continue
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		digest, err := checksum.ComputeFile(filePath)
+		if err != nil || digest.Sum != entry.SHA256 {
+			report.Modified = append(report.Modified, entry.Path)
+		}
+	}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if rel = filepath.ToSlash(rel); !expected[rel] {
+			report.Extra = append(report.Extra, rel)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, ErrFailedToReadDirectory
+	}
+
+	return report, nil
+}
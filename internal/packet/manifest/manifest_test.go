@@ -0,0 +1,106 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+)
+
+func makeInstalled(t *testing.T) (string, *Manifest) {
+	root := t.TempDir()
+	m := &Manifest{}
+
+	for _, name := range []string{"a.txt", filepath.Join("data", "b.txt")} {
+		filePath := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+			t.Fatal("Failed to create directory:", err)
+		}
+
+		if err := os.WriteFile(filePath, []byte("some text"), 0644); err != nil {
+			t.Fatal("Failed to create test file:", err)
+		}
+
+		digest, err := checksum.ComputeFile(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m.Add(Entry{Path: filepath.ToSlash(name), Size: digest.Size, Mode: 0644, SHA256: digest.Sum})
+	}
+
+	return root, m
+}
+
+func TestVerify_Unchanged(t *testing.T) {
+	root, m := makeInstalled(t)
+
+	report, err := m.Verify(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.OK() {
+		t.Fatalf("Unexpected differences: %+v", report)
+	}
+}
+
+func TestVerify_Changes(t *testing.T) {
+	root, m := makeInstalled(t)
+
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("some test"), 0644); err != nil {
+		t.Fatal("Failed to modify test file:", err)
+	}
+
+	if err := os.Remove(filepath.Join(root, "data", "b.txt")); err != nil {
+		t.Fatal("Failed to remove test file:", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "data", "c.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal("Failed to create test file:", err)
+	}
+
+	report, err := m.Verify(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Modified) != 1 || report.Modified[0] != "a.txt" {
+		t.Fatalf("Invalid modified files: %v", report.Modified)
+	}
+
+	if len(report.Missing) != 1 || report.Missing[0] != "data/b.txt" {
+		t.Fatalf("Invalid missing files: %v", report.Missing)
+	}
+
+	if len(report.Extra) != 1 || report.Extra[0] != "data/c.txt" {
+		t.Fatalf("Invalid extra files: %v", report.Extra)
+	}
+}
+
+func TestVerify_ModeChanged(t *testing.T) {
+	root, m := makeInstalled(t)
+	if err := os.Chmod(filepath.Join(root, "a.txt"), 0755); err != nil {
+		t.Fatal("Failed to change mode:", err)
+	}
+
+	report, err := m.Verify(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !report.OK() {
+		t.Fatalf("Modes should not be compared: %+v", report)
+	}
+
+	m.ModesPreserved = true
+	report, err = m.Verify(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Modified) != 1 || report.Modified[0] != "a.txt" {
+		t.Fatalf("Invalid modified files: %v", report.Modified)
+	}
+}
@@ -0,0 +1,146 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/Elementary1092/pm/internal/packet/archiver"
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+	"github.com/Elementary1092/pm/internal/packet/parser"
+)
+
+var (
+	ErrInvalidMetadataFormat = errors.New("invalid package metadata format")
+)
+
+// Metadata is stored on the server next to every published version of a package
+type Metadata struct {
+	Packets []parser.PackageDescription `json:"packets,omitempty"`
+	// SHA-256 checksum and size of the archive
+	Checksum string `json:"sha256,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	License  string `json:"license,omitempty"`
+	// Format of the archive, packages published before formats were introduced are zip archives
+	Format archiver.Format `json:"format,omitempty"`
+	// SHA-256 checksum and size of the manifest of all package files published next to the metadata
+	ManifestChecksum string `json:"manifest_sha256,omitempty"`
+	ManifestSize     int64  `json:"manifest_size,omitempty"`
+	// Delta is nil if the package could be installed only from the full archive
+	Delta *Delta `json:"delta,omitempty"`
+}
+
+// Delta is an archive of files which differ from the previous version of a package.
+// It has the same format as the full archive.
+type Delta struct {
+	From     string `json:"from"`
+	Checksum string `json:"sha256"`
+	Size     int64  `json:"size"`
+}
+
+// Digest returns checksum of the delta archive
+func (d *Delta) Digest() checksum.Digest {
+	return checksum.Digest{
+		Sum:  d.Checksum,
+		Size: d.Size,
+	}
+}
+
+func New(description *parser.Packet) *Metadata {
+	format, err := archiver.ParseFormat(description.Format)
+	if err != nil {
+		format = archiver.DefaultFormat
+	}
+
+	return &Metadata{
+		Packets: description.Packets,
+		License: description.License,
+		Format:  format,
+	}
+}
+
+// Decode also accepts metadata of packages published before checksums were introduced:
+// such metadata is a plain list of dependencies.
+func Decode(data io.Reader) (*Metadata, error) {
+	raw, err := io.ReadAll(data)
+	if err != nil {
+		return nil, ErrInvalidMetadataFormat
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		var packets []parser.PackageDescription
+		if err := json.Unmarshal(raw, &packets); err != nil {
+			return nil, ErrInvalidMetadataFormat
+		}
+
+		return &Metadata{
+			Packets: packets,
+		}, nil
+	}
+
+	var meta Metadata
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return nil, ErrInvalidMetadataFormat
+	}
+
+	if _, err := archiver.ParseFormat(string(meta.Format)); err != nil {
+		return nil, err
+	}
+
+	return &meta, nil
+}
+
+func (m *Metadata) Encode(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(m)
+}
+
+// SetDigest records checksum of the archive
+func (m *Metadata) SetDigest(digest checksum.Digest) {
+	m.Checksum = digest.Sum
+	m.Size = digest.Size
+}
+
+// Digest returns checksum of the archive.
+// false is returned if the package was published without a checksum.
+func (m *Metadata) Digest() (checksum.Digest, bool) {
+	if m.Checksum == "" {
+		return checksum.Digest{}, false
+	}
+
+	return checksum.Digest{
+		Sum:  m.Checksum,
+		Size: m.Size,
+	}, true
+}
+
+// ArchiveFormat returns format of the archive
+func (m *Metadata) ArchiveFormat() archiver.Format {
+	if m.Format == "" {
+		return archiver.DefaultFormat
+	}
+
+	return m.Format
+}
+
+// SetManifestDigest records checksum of the published manifest
+func (m *Metadata) SetManifestDigest(digest checksum.Digest) {
+	m.ManifestChecksum = digest.Sum
+	m.ManifestSize = digest.Size
+}
+
+// ManifestDigest returns checksum of the published manifest.
+// false is returned if the package was published without a manifest.
+func (m *Metadata) ManifestDigest() (checksum.Digest, bool) {
+	if m.ManifestChecksum == "" {
+		return checksum.Digest{}, false
+	}
+
+	return checksum.Digest{
+		Sum:  m.ManifestChecksum,
+		Size: m.ManifestSize,
+	}, true
+}
@@ -0,0 +1,129 @@
+package metadata
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Elementary1092/pm/internal/packet/archiver"
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+	"github.com/Elementary1092/pm/internal/packet/parser"
+)
+
+func TestDecode_Legacy(t *testing.T) {
+	meta, err := Decode(strings.NewReader(`[{"name": "packet-3", "ver": "<=2.0"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(meta.Packets) != 1 || meta.Packets[0].Name != "packet-3" {
+		t.Fatal("invalid decoding")
+	}
+
+	if _, ok := meta.Digest(); ok {
+		t.Fatal("legacy metadata has no digest")
+	}
+}
+
+func TestDecode_LegacyNull(t *testing.T) {
+	meta, err := Decode(strings.NewReader("null\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(meta.Packets) != 0 {
+		t.Fatal("invalid decoding")
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	if _, err := Decode(strings.NewReader(`{"packets": 1}`)); !errors.Is(err, ErrInvalidMetadataFormat) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidMetadataFormat, err)
+	}
+}
+
+func TestEncode_Decode(t *testing.T) {
+	meta := &Metadata{
+		Packets: []parser.PackageDescription{{Name: "packet-3", Version: "<=2.0"}},
+	}
+	meta.SetDigest(checksum.Digest{Sum: "abc", Size: 10})
+
+	var buf bytes.Buffer
+	if err := meta.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, ok := decoded.Digest()
+	if !ok || digest.Sum != "abc" || digest.Size != 10 {
+		t.Fatalf("Invalid digest: sum='%s'; size=%d", digest.Sum, digest.Size)
+	}
+
+	if len(decoded.Packets) != 1 || decoded.Packets[0].Version != "<=2.0" {
+		t.Fatal("invalid dependencies")
+	}
+}
+
+func TestArchiveFormat(t *testing.T) {
+	legacy, err := Decode(strings.NewReader(`{"sha256": "abc", "size": 10}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if legacy.ArchiveFormat() != archiver.FormatZip {
+		t.Fatalf("Invalid format of legacy metadata: %s", legacy.ArchiveFormat())
+	}
+
+	meta := New(&parser.Packet{Name: "a", Version: "1.0", Format: "tar.gz"})
+	if meta.ArchiveFormat() != archiver.FormatTarGz {
+		t.Fatalf("Invalid format: %s", meta.ArchiveFormat())
+	}
+}
+
+func TestDecode_UnknownFormat(t *testing.T) {
+	if _, err := Decode(strings.NewReader(`{"format": "rar"}`)); !errors.Is(err, archiver.ErrUnsupportedFormat) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", archiver.ErrUnsupportedFormat, err)
+	}
+}
+
+func TestEncode_Decode_Delta(t *testing.T) {
+	meta := &Metadata{
+		Delta: &Delta{From: "1.0", Checksum: "def", Size: 5},
+	}
+	meta.SetManifestDigest(checksum.Digest{Sum: "abc", Size: 10})
+
+	var buf bytes.Buffer
+	if err := meta.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest, ok := decoded.ManifestDigest()
+	if !ok || digest.Sum != "abc" || digest.Size != 10 {
+		t.Fatalf("Invalid manifest digest: sum='%s'; size=%d", digest.Sum, digest.Size)
+	}
+
+	if decoded.Delta == nil || decoded.Delta.From != "1.0" || decoded.Delta.Digest().Sum != "def" {
+		t.Fatalf("Invalid delta: %+v", decoded.Delta)
+	}
+}
+
+func TestDecode_WithoutDelta(t *testing.T) {
+	meta, err := Decode(strings.NewReader(`{"sha256": "abc", "size": 10}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := meta.ManifestDigest(); ok || meta.Delta != nil {
+		t.Fatal("metadata has neither manifest nor delta")
+	}
+}
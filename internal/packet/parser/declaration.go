@@ -14,15 +14,130 @@ var (
 )
 
 type Targets struct {
-	Path    string `json:"path" validate:"min=1"`
-	Exclude string `json:"exclude,omitempty" validate:"omitempty,min=1"`
+	Path string `json:"path" validate:"min=1"`
+	// Base is a local directory files of the target are placed relative to instead of the package root,
+	// Path should be inside of it
+	Base string `json:"base,omitempty"`
+	// Exclude patterns have .gitignore semantics, patterns starting with "!" include files back
+	Exclude Patterns `json:"exclude,omitempty" validate:"omitempty,dive,min=1"`
+	// Dest is a directory in the package files of the target are placed to
+	Dest string `json:"dest,omitempty" validate:"package_path"`
+	// StripPrefix is a local directory which is removed from paths of files of the target,
+	// it is the base directory of Path if only Dest is set
+	StripPrefix string `json:"strip_prefix,omitempty"`
+	// Required target should match at least one file
+	Required bool `json:"required,omitempty"`
+	// MinFiles is the least number of files the target should match
+	MinFiles int `json:"min_files,omitempty" validate:"min=0"`
+	// Symlinks overrides the symbolic link policy of the package for files of the target
+	Symlinks string `json:"symlinks,omitempty" validate:"omitempty,oneof=skip follow preserve"`
+}
+
+// Symbolic link policies
+const (
+	// SymlinksSkip ignores symbolic links
+	SymlinksSkip = "skip"
+	// SymlinksFollow stores files and directories links point to as if they were in place of the links
+	SymlinksFollow = "follow"
+	// SymlinksPreserve stores symbolic links as links
+	SymlinksPreserve = "preserve"
+)
+
+// MinimumFiles returns the least number of files the target should match, 0 if it could match nothing
+func (t Targets) MinimumFiles() int {
+	if t.MinFiles == 0 && t.Required {
+		return 1
+	}
+
+	return t.MinFiles
+}
+
+// Patterns is a list of patterns which could also be declared as a single string
+type Patterns []string
+
+func (p *Patterns) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*p = Patterns{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+
+	*p = list
+	return nil
 }
 
 type Packet struct {
-	Name    string               `json:"name" validate:"min=1"`
+	Name    string               `json:"name" validate:"min=1,pack_name"`
 	Version string               `json:"ver" validate:"min=1,pack_ver"`
 	Targets []Targets            `json:"targets" validate:"min=1,dive"`
 	Packets []PackageDescription `json:"packets,omitempty" validate:"omitempty,dive"`
+	// Root is a local directory files are placed relative to, it is the common directory of targets without Base by default.
+	// Targets outside of it are refused.
+	Root string `json:"root,omitempty"`
+	// License is an SPDX license expression, e.g. "MIT" or "Apache-2.0 OR MIT"
+	License string `json:"license,omitempty" validate:"omitempty,printascii"`
+	// Format of the package archive: zip (default), tar.gz or tar.zst
+	Format string `json:"format,omitempty" validate:"omitempty,oneof=zip tar.gz tar.zst"`
+	// Preserve switches off storing of file attributes, everything except symbolic links is preserved by default
+	Preserve *PreserveDeclaration `json:"preserve,omitempty"`
+	// Reproducible normalizes permission bits and modification times of archived files
+	Reproducible bool `json:"reproducible,omitempty"`
+	// CompressionLevel from 1 (fastest) to 9 (smallest archive), default level of the format is used if it is 0
+	CompressionLevel int `json:"compression_level,omitempty" validate:"min=0,max=9"`
+	// Symlinks is the symbolic link policy of every target: skip, follow or preserve
+	Symlinks string `json:"symlinks,omitempty" validate:"omitempty,oneof=skip follow preserve"`
+}
+
+// SymlinkPolicy returns the symbolic link policy of the package.
+// Links are skipped by default, unless preserving them is switched on.
+func (p *Packet) SymlinkPolicy() string {
+	switch {
+	case p.Symlinks != "":
+		return p.Symlinks
+	case p.PreserveOptions().Symlinks:
+		return SymlinksPreserve
+	}
+
+	return SymlinksSkip
+}
+
+// PreserveDeclaration lists file attributes which are stored in the archive and restored on extraction.
+// Missing field means true, except symlinks: links are preserved only if it is set.
+type PreserveDeclaration struct {
+	Mode      *bool `json:"mode,omitempty"`
+	ModTime   *bool `json:"mtime,omitempty"`
+	Symlinks  *bool `json:"symlinks,omitempty"`
+	EmptyDirs *bool `json:"empty_dirs,omitempty"`
+}
+
+// Preserve is PreserveDeclaration with defaults applied
+type Preserve struct {
+	Mode      bool
+	ModTime   bool
+	Symlinks  bool
+	EmptyDirs bool
+}
+
+func (p *Packet) PreserveOptions() Preserve {
+	if p.Preserve == nil {
+		return Preserve{Mode: true, ModTime: true, Symlinks: false, EmptyDirs: true}
+	}
+
+	return Preserve{
+		Mode:      isEnabled(p.Preserve.Mode),
+		ModTime:   isEnabled(p.Preserve.ModTime),
+		Symlinks:  p.Preserve.Symlinks != nil && *p.Preserve.Symlinks,
+		EmptyDirs: isEnabled(p.Preserve.EmptyDirs),
+	}
+}
+
+func isEnabled(b *bool) bool {
+	return b == nil || *b
 }
 
 func ParsePacket(data io.Reader) (*Packet, error) {
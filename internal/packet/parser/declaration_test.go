@@ -191,3 +191,323 @@ func TestParsePacket_Valid(t *testing.T) {
 		t.Fatal("unexpected nil result")
 	}
 }
+
+func TestParsePacket_WithDependencyGroups(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "targets": [
+            {"path": "./"}
+        ],
+        "packets": [
+        {"name": "some_pack", "ver": "1.0"},
+        {"name": "linter", "group": "dev"},
+        {"name": "compiler", "ver": ">=2.0", "group": "build"},
+        {"name": "plugin", "group": "optional"}
+        ]
+    }`
+
+	reader := bytes.NewReader([]byte(data))
+
+	res, err := ParsePacket(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Packets[2].DependencyGroup() != GroupBuild {
+		t.Fatal("invalid dependency group")
+	}
+}
+
+func TestParsePacket_WithLicense(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "license": "Apache-2.0 OR MIT",
+        "targets": [
+            {"path": "./"}
+        ]
+    }`
+
+	reader := bytes.NewReader([]byte(data))
+
+	res, err := ParsePacket(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.License != "Apache-2.0 OR MIT" {
+		t.Fatalf("invalid license: %s", res.License)
+	}
+}
+
+func TestParsePacket_WithFormat(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "format": "tar.zst",
+        "targets": [
+            {"path": "./"}
+        ]
+    }`
+
+	res, err := ParsePacket(bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Format != "tar.zst" {
+		t.Fatalf("invalid format: %s", res.Format)
+	}
+}
+
+func TestParsePacket_WithUnknownFormat(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "format": "rar",
+        "targets": [
+            {"path": "./"}
+        ]
+    }`
+
+	if _, err := ParsePacket(bytes.NewReader([]byte(data))); !errors.Is(err, ErrInvalidPacketDescription) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidPacketDescription, err)
+	}
+}
+
+func TestParsePacket_WithCompressionLevel(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "compression_level": 9,
+        "targets": [
+            {"path": "./"}
+        ]
+    }`
+
+	res, err := ParsePacket(bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.CompressionLevel != 9 {
+		t.Fatalf("invalid compression level: %d", res.CompressionLevel)
+	}
+}
+
+func TestParsePacket_WithInvalidCompressionLevel(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "compression_level": 10,
+        "targets": [
+            {"path": "./"}
+        ]
+    }`
+
+	if _, err := ParsePacket(bytes.NewReader([]byte(data))); !errors.Is(err, ErrInvalidPacketDescription) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidPacketDescription, err)
+	}
+}
+
+func TestParsePacket_WithPreserve(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "preserve": {"mtime": false, "symlinks": true},
+        "targets": [
+            {"path": "./"}
+        ]
+    }`
+
+	res, err := ParsePacket(bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Preserve{Mode: true, ModTime: false, Symlinks: true, EmptyDirs: true}
+	if res.PreserveOptions() != expected {
+		t.Fatalf("invalid preserve options: %+v", res.PreserveOptions())
+	}
+}
+
+func TestParsePacket_PreserveByDefault(t *testing.T) {
+	data := `{"name": "a", "ver": "1.0", "targets": [{"path": "./"}]}`
+
+	res, err := ParsePacket(bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Preserve{Mode: true, ModTime: true, Symlinks: false, EmptyDirs: true}
+	if res.PreserveOptions() != expected {
+		t.Fatalf("invalid preserve options: %+v", res.PreserveOptions())
+	}
+}
+
+func TestParsePacket_WithExcludeList(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "targets": [
+            {"path": "./*", "exclude": ["*.log", "!keep.log"]},
+            {"path": "./b/*", "exclude": "*.tmp"}
+        ]
+    }`
+
+	res, err := ParsePacket(bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Targets[0].Exclude) != 2 || res.Targets[0].Exclude[1] != "!keep.log" {
+		t.Fatalf("invalid exclude list: %v", res.Targets[0].Exclude)
+	}
+
+	if len(res.Targets[1].Exclude) != 1 || res.Targets[1].Exclude[0] != "*.tmp" {
+		t.Fatalf("invalid single exclude: %v", res.Targets[1].Exclude)
+	}
+}
+
+func TestParsePacket_WithEmptyExcludePattern(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "targets": [
+            {"path": "./*", "exclude": ["*.log", ""]}
+        ]
+    }`
+
+	if _, err := ParsePacket(bytes.NewReader([]byte(data))); !errors.Is(err, ErrInvalidPacketDescription) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidPacketDescription, err)
+	}
+}
+
+func TestParsePacket_WithDestination(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "targets": [
+            {"path": "./*"},
+            {"path": "../shared/config/*", "dest": "config", "strip_prefix": "../shared"}
+        ]
+    }`
+
+	res, err := ParsePacket(bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Targets[0].Dest != "" || res.Targets[0].StripPrefix != "" {
+		t.Fatalf("invalid destination: %+v", res.Targets[0])
+	}
+
+	if res.Targets[1].Dest != "config" || res.Targets[1].StripPrefix != "../shared" {
+		t.Fatalf("invalid destination: %+v", res.Targets[1])
+	}
+}
+
+func TestParsePacket_WithDestinationOutsidePackage(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "targets": [
+            {"path": "./*", "dest": "../outside"}
+        ]
+    }`
+
+	if _, err := ParsePacket(bytes.NewReader([]byte(data))); !errors.Is(err, ErrInvalidPacketDescription) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidPacketDescription, err)
+	}
+}
+
+func TestParsePacket_WithRequiredTargets(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "targets": [
+            {"path": "./a/*"},
+            {"path": "./b/*", "required": true},
+            {"path": "./c/*", "min_files": 3}
+        ]
+    }`
+
+	res, err := ParsePacket(bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []int{0, 1, 3} {
+		if res.Targets[i].MinimumFiles() != expected {
+			t.Fatalf("invalid minimum number of files of target %d: expected=%d; got=%d", i, expected, res.Targets[i].MinimumFiles())
+		}
+	}
+}
+
+func TestParsePacket_WithNegativeMinFiles(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "targets": [
+            {"path": "./*", "min_files": -1}
+        ]
+    }`
+
+	if _, err := ParsePacket(bytes.NewReader([]byte(data))); !errors.Is(err, ErrInvalidPacketDescription) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidPacketDescription, err)
+	}
+}
+
+func TestParsePacket_WithSymlinkPolicy(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "symlinks": "follow",
+        "targets": [
+            {"path": "./a/*"},
+            {"path": "./b/*", "symlinks": "skip"}
+        ]
+    }`
+
+	res, err := ParsePacket(bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.SymlinkPolicy() != SymlinksFollow || res.Targets[1].Symlinks != SymlinksSkip {
+		t.Fatalf("invalid symlink policies: %s, %s", res.SymlinkPolicy(), res.Targets[1].Symlinks)
+	}
+}
+
+func TestParsePacket_DefaultSymlinkPolicy(t *testing.T) {
+	res := &Packet{}
+	if res.SymlinkPolicy() != SymlinksSkip {
+		t.Fatalf("links should be skipped by default: %s", res.SymlinkPolicy())
+	}
+
+	res.Preserve = &PreserveDeclaration{}
+	if res.SymlinkPolicy() != SymlinksSkip {
+		t.Fatalf("links should be skipped unless they are preserved: %s", res.SymlinkPolicy())
+	}
+
+	preserve := true
+	res.Preserve.Symlinks = &preserve
+	if res.SymlinkPolicy() != SymlinksPreserve {
+		t.Fatalf("links should be preserved if it is declared: %s", res.SymlinkPolicy())
+	}
+}
+
+func TestParsePacket_WithUnknownSymlinkPolicy(t *testing.T) {
+	data := `{
+        "name": "a",
+        "ver": "1.0",
+        "targets": [
+            {"path": "./*", "symlinks": "copy"}
+        ]
+    }`
+
+	if _, err := ParsePacket(bytes.NewReader([]byte(data))); !errors.Is(err, ErrInvalidPacketDescription) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidPacketDescription, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"strings"
 
 	validate "github.com/Elementary1092/pm/internal/packet/validator"
 )
@@ -13,9 +14,27 @@ var (
 	ErrInvalidPackageDescription       = errors.New("invalid package description")
 )
 
+// Dependency groups. Dependencies without a group belong to GroupRuntime.
+const (
+	GroupRuntime  = "runtime"
+	GroupDev      = "dev"
+	GroupBuild    = "build"
+	GroupOptional = "optional"
+)
+
 type PackageDescription struct {
-	Name    string `json:"name" validate:"required"`
+	Name    string `json:"name" validate:"required,pack_name"`
 	Version string `json:"ver,omitempty" validate:"remote_ver"`
+	Group   string `json:"group,omitempty" validate:"omitempty,dep_group"`
+}
+
+// DependencyGroup returns the group of a dependency taking default group into account
+func (p PackageDescription) DependencyGroup() string {
+	if p.Group == "" {
+		return GroupRuntime
+	}
+
+	return p.Group
 }
 
 type Package struct {
@@ -38,3 +57,29 @@ func ParsePackage(data io.Reader) (*Package, error) {
 
 	return &pack, nil
 }
+
+var (
+	ErrInvalidPackageReference = errors.New("invalid package reference")
+)
+
+// ParseReference parses a published package reference of the form name@version
+func ParseReference(ref string) (*PackageDescription, error) {
+	at := strings.LastIndex(ref, "@")
+	if at == -1 {
+		return nil, ErrInvalidPackageReference
+	}
+
+	pack := PackageDescription{
+		Name:    ref[:at],
+		Version: ref[at+1:],
+	}
+	if err := validate.Validator().Var(pack.Version, "min=1,pack_ver"); err != nil {
+		return nil, ErrInvalidPackageReference
+	}
+
+	if err := validate.Validator().Struct(pack); err != nil {
+		return nil, ErrInvalidPackageReference
+	}
+
+	return &pack, nil
+}
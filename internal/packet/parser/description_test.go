@@ -95,3 +95,72 @@ func TestParsePackage_Valid(t *testing.T) {
 		t.Fatal("unexpected nil result")
 	}
 }
+
+func TestParseReference_Valid(t *testing.T) {
+	res, err := ParseReference("packet-1@1.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Name != "packet-1" || res.Version != "1.10" {
+		t.Fatalf("invalid reference: name='%s'; ver='%s'", res.Name, res.Version)
+	}
+}
+
+func TestParseReference_WithoutVersion(t *testing.T) {
+	if _, err := ParseReference("packet-1@"); !errors.Is(err, ErrInvalidPackageReference) {
+		t.Fail()
+	}
+}
+
+func TestParseReference_WithConstraint(t *testing.T) {
+	if _, err := ParseReference("packet-1@>=1.10"); !errors.Is(err, ErrInvalidPackageReference) {
+		t.Fail()
+	}
+}
+
+func TestParseReference_WithoutName(t *testing.T) {
+	if _, err := ParseReference("@1.10"); !errors.Is(err, ErrInvalidPackageReference) {
+		t.Fail()
+	}
+}
+
+func TestParseReference_WithoutAt(t *testing.T) {
+	if _, err := ParseReference("packet-1"); !errors.Is(err, ErrInvalidPackageReference) {
+		t.Fail()
+	}
+}
+
+func TestParsePackage_WithGroups(t *testing.T) {
+	data := `{
+        "packages": [
+        {"name": "some_pack", "ver": "1.0", "group": "dev"},
+        {"name": "pack"}
+        ]
+    }`
+
+	reader := bytes.NewReader([]byte(data))
+
+	res, err := ParsePackage(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Packages[0].DependencyGroup() != GroupDev || res.Packages[1].DependencyGroup() != GroupRuntime {
+		t.Fatal("invalid dependency groups")
+	}
+}
+
+func TestParsePackage_WithUnknownGroup(t *testing.T) {
+	data := `{
+        "packages": [
+        {"name": "some_pack", "ver": "1.0", "group": "test"}
+        ]
+    }`
+
+	reader := bytes.NewReader([]byte(data))
+
+	if _, err := ParsePackage(reader); err == nil || !errors.Is(err, ErrInvalidPackageDescription) {
+		t.Fail()
+	}
+}
@@ -0,0 +1,188 @@
+package resolver
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Elementary1092/pm/internal/packet/parser"
+)
+
+func (n *Node) String() string {
+	return n.Name + "@" + n.Version
+}
+
+func (e Edge) String() string {
+	return fmt.Sprintf("%s (%s)", e.To, e.label())
+}
+
+// label describes the constraint and the group of non-runtime dependencies
+func (e Edge) label() string {
+	label := e.Constraint
+	if label == "" {
+		label = "latest"
+	}
+
+	if e.Group != "" && e.Group != parser.GroupRuntime {
+		label += ", " + e.Group
+	}
+
+	return label
+}
+
+// WriteTree prints the graph as a tree.
+// Packages which were already printed with their dependencies are marked with (*).
+func (g *Graph) WriteTree(w io.Writer, rootLabel string) error {
+	if _, err := fmt.Fprintln(w, rootLabel); err != nil {
+		return err
+	}
+
+	expanded := make(map[*Node]bool)
+	return writeSubtree(w, g.Roots, "", expanded)
+}
+
+func writeSubtree(w io.Writer, edges []Edge, indent string, expanded map[*Node]bool) error {
+	for i, edge := range edges {
+		branch, nextIndent := "├── ", indent+"│   "
+		if i == len(edges)-1 {
+			branch, nextIndent = "└── ", indent+"    "
+		}
+
+		suffix := ""
+		if expanded[edge.To] && len(edge.To.Deps) != 0 {
+			suffix = " (*)"
+		}
+
+		if _, err := fmt.Fprintf(w, "%s%s%s%s\n", indent, branch, edge, suffix); err != nil {
+			return err
+		}
+
+		if expanded[edge.To] {
+			continue
+		}
+		expanded[edge.To] = true
+
+		if err := writeSubtree(w, edge.To.Deps, nextIndent, expanded); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Paths returns every path from the roots of the graph to the package with the given name.
+// Cycles are not followed.
+func (g *Graph) Paths(name string) [][]Edge {
+	paths := make([][]Edge, 0)
+	onPath := make(map[*Node]bool)
+
+	var walk func(edges []Edge, path []Edge)
+	walk = func(edges []Edge, path []Edge) {
+		for _, edge := range edges {
+			if onPath[edge.To] {
+				continue
+			}
+
+			current := append(path[:len(path):len(path)], edge)
+			if edge.To.Name == name {
+				paths = append(paths, current)
+				continue
+			}
+
+			onPath[edge.To] = true
+			walk(edge.To.Deps, current)
+			onPath[edge.To] = false
+		}
+	}
+	walk(g.Roots, nil)
+
+	return paths
+}
+
+// WritePaths prints each path on a separate line
+func WritePaths(w io.Writer, rootLabel string, paths [][]Edge) error {
+	for _, path := range paths {
+		parts := make([]string, 0, len(path)+1)
+		parts = append(parts, rootLabel)
+		for _, edge := range path {
+			parts = append(parts, edge.String())
+		}
+
+		if _, err := fmt.Fprintln(w, strings.Join(parts, " -> ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteDot prints the graph in Graphviz DOT format
+func (g *Graph) WriteDot(w io.Writer, rootLabel string) error {
+	arcs := make([]arc, 0)
+	for _, edge := range g.Roots {
+		arcs = append(arcs, arc{from: rootLabel, edge: edge})
+	}
+
+	visited := make(map[*Node]bool)
+	queue := make([]*Node, 0)
+	for _, edge := range g.Roots {
+		if !visited[edge.To] {
+			visited[edge.To] = true
+			queue = append(queue, edge.To)
+		}
+	}
+
+	for len(queue) != 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, edge := range node.Deps {
+			arcs = append(arcs, arc{from: node.String(), edge: edge})
+			if !visited[edge.To] {
+				visited[edge.To] = true
+				queue = append(queue, edge.To)
+			}
+		}
+	}
+
+	return writeDot(w, rootLabel, arcs)
+}
+
+// WritePathsDot prints only the given paths in Graphviz DOT format
+func WritePathsDot(w io.Writer, rootLabel string, paths [][]Edge) error {
+	arcs := make([]arc, 0)
+	for _, path := range paths {
+		from := rootLabel
+		for _, edge := range path {
+			arcs = append(arcs, arc{from: from, edge: edge})
+			from = edge.To.String()
+		}
+	}
+
+	return writeDot(w, rootLabel, arcs)
+}
+
+type arc struct {
+	from string
+	edge Edge
+}
+
+// writeDot prints every arc once
+func writeDot(w io.Writer, rootLabel string, arcs []arc) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", rootLabel)
+	fmt.Fprintf(&b, "\t%q [shape=box];\n", rootLabel)
+
+	printed := make(map[string]bool)
+	for _, a := range arcs {
+		line := fmt.Sprintf("\t%q -> %q [label=%q];\n", a.from, a.edge.To.String(), a.edge.label())
+		if printed[line] {
+			continue
+		}
+		printed[line] = true
+		b.WriteString(line)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
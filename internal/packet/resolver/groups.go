@@ -0,0 +1,86 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Elementary1092/pm/internal/packet/parser"
+)
+
+var (
+	ErrUnknownGroup = errors.New("unknown dependency group")
+)
+
+var knownGroups = []string{
+	parser.GroupRuntime,
+	parser.GroupDev,
+	parser.GroupBuild,
+	parser.GroupOptional,
+}
+
+// Groups is a set of dependency groups which should be resolved
+type Groups map[string]bool
+
+// DefaultGroups contains only runtime dependencies
+func DefaultGroups() Groups {
+	return Groups{parser.GroupRuntime: true}
+}
+
+// NewGroups adds with to and removes without from the default groups
+func NewGroups(with []string, without []string) (Groups, error) {
+	groups := DefaultGroups()
+	for _, group := range with {
+		if !isKnownGroup(group) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownGroup, group)
+		}
+		groups[group] = true
+	}
+
+	for _, group := range without {
+		if !isKnownGroup(group) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownGroup, group)
+		}
+		delete(groups, group)
+	}
+
+	return groups, nil
+}
+
+// Includes reports whether a dependency listed directly in a package description file should be resolved
+func (g Groups) Includes(dep parser.PackageDescription) bool {
+	return g[dep.DependencyGroup()]
+}
+
+// IncludesTransitive reports whether a dependency of a resolved package should be resolved.
+// Development and build dependencies are needed only to work on a package itself,
+// so they are never resolved transitively.
+func (g Groups) IncludesTransitive(dep parser.PackageDescription) bool {
+	group := dep.DependencyGroup()
+	if group == parser.GroupDev || group == parser.GroupBuild {
+		return false
+	}
+
+	return g[group]
+}
+
+// Filter returns dependencies which belong to the groups
+func (g Groups) Filter(deps []parser.PackageDescription) []parser.PackageDescription {
+	filtered := make([]parser.PackageDescription, 0, len(deps))
+	for _, dep := range deps {
+		if g.Includes(dep) {
+			filtered = append(filtered, dep)
+		}
+	}
+
+	return filtered
+}
+
+func isKnownGroup(group string) bool {
+	for _, known := range knownGroups {
+		if group == known {
+			return true
+		}
+	}
+
+	return false
+}
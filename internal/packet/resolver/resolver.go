@@ -0,0 +1,166 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Elementary1092/pm/internal/packet/parser"
+	"github.com/Elementary1092/pm/internal/version"
+)
+
+var (
+	ErrNoSatisfyingVersion = errors.New("no satisfying version")
+	ErrVersionConflict     = errors.New("version conflict")
+)
+
+// Repository provides information about published packages
+type Repository interface {
+	// Versions returns all published versions of a package
+	Versions(ctx context.Context, name string) ([]string, error)
+	// Dependencies returns dependencies declared by the package of the given version
+	Dependencies(ctx context.Context, name string, ver string) ([]parser.PackageDescription, error)
+}
+
+// Node is a package with the selected version
+type Node struct {
+	Name    string
+	Version string
+	Deps    []Edge
+}
+
+// Edge describes which constraint of which dependency group pulled in a package
+type Edge struct {
+	Constraint string
+	Group      string
+	To         *Node
+}
+
+// Graph is a resolved dependency graph.
+// Roots are packages listed in a package description file.
+type Graph struct {
+	Roots []Edge
+	Nodes map[string]*Node
+}
+
+// SelectVersion returns the highest published version of a package satisfying constraint
+func SelectVersion(ctx context.Context, repo Repository, name string, constraint string) (string, error) {
+	versions, err := repo.Versions(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	selected := version.Latest(versions, constraint)
+	if selected == "" {
+		return "", fmt.Errorf("%w: %s", ErrNoSatisfyingVersion, describe(name, constraint))
+	}
+
+	return selected, nil
+}
+
+// Resolve selects a version for each package of the groups reachable from roots.
+// A package gets a single version: the one selected on its first occurrence in breadth-first order.
+// Any later constraint not satisfied by it results in ErrVersionConflict.
+func Resolve(ctx context.Context, repo Repository, roots []parser.PackageDescription, groups Groups) (*Graph, error) {
+	graph := &Graph{
+		Roots: make([]Edge, 0, len(roots)),
+		Nodes: make(map[string]*Node),
+	}
+
+	queue := make([]*Node, 0)
+	link := func(dep parser.PackageDescription) (Edge, error) {
+		if node, ok := graph.Nodes[dep.Name]; ok {
+			satisfied, err := version.Satisfies(node.Version, dep.Version)
+			if err != nil || !satisfied {
+				return Edge{}, fmt.Errorf("%w: %s is selected, but %s is required",
+					ErrVersionConflict, node.Name+"@"+node.Version, describe(dep.Name, dep.Version))
+			}
+
+			return Edge{Constraint: dep.Version, Group: dep.DependencyGroup(), To: node}, nil
+		}
+
+		selected, err := SelectVersion(ctx, repo, dep.Name, dep.Version)
+		if err != nil {
+			return Edge{}, err
+		}
+
+		node := &Node{
+			Name:    dep.Name,
+			Version: selected,
+		}
+		graph.Nodes[dep.Name] = node
+		queue = append(queue, node)
+
+		return Edge{Constraint: dep.Version, Group: dep.DependencyGroup(), To: node}, nil
+	}
+
+	for _, root := range groups.Filter(roots) {
+		edge, err := link(root)
+		if err != nil {
+			return nil, err
+		}
+		graph.Roots = append(graph.Roots, edge)
+	}
+
+	for len(queue) != 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		deps, err := repo.Dependencies(ctx, node.Name, node.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dep := range deps {
+			if !groups.IncludesTransitive(dep) {
+				continue
+			}
+
+			edge, err := link(dep)
+			if err != nil {
+				return nil, err
+			}
+			node.Deps = append(node.Deps, edge)
+		}
+	}
+
+	return graph, nil
+}
+
+func describe(name string, constraint string) string {
+	if constraint == "" {
+		return name + " (latest)"
+	}
+
+	return name + " " + constraint
+}
+
+// Unresolvable describes a dependency without a satisfying published version
+type Unresolvable struct {
+	Dependency parser.PackageDescription
+	Err        error
+}
+
+func (u Unresolvable) String() string {
+	reason := u.Err
+	if errors.Is(reason, ErrNoSatisfyingVersion) {
+		reason = ErrNoSatisfyingVersion
+	}
+
+	return fmt.Sprintf("%s: %v", describe(u.Dependency.Name, u.Dependency.Version), reason)
+}
+
+// CheckDependencies returns every dependency which cannot be resolved against the repository
+func CheckDependencies(ctx context.Context, repo Repository, deps []parser.PackageDescription) []Unresolvable {
+	unresolvable := make([]Unresolvable, 0)
+	for _, dep := range deps {
+		if _, err := SelectVersion(ctx, repo, dep.Name, dep.Version); err != nil {
+			unresolvable = append(unresolvable, Unresolvable{
+				Dependency: dep,
+				Err:        err,
+			})
+		}
+	}
+
+	return unresolvable
+}
@@ -0,0 +1,291 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Elementary1092/pm/internal/packet/parser"
+	"github.com/Elementary1092/pm/internal/repository"
+)
+
+type fakeRepository map[string]map[string][]parser.PackageDescription
+
+func (f fakeRepository) Versions(ctx context.Context, name string) ([]string, error) {
+	versions := make([]string, 0)
+	for ver := range f[name] {
+		versions = append(versions, ver)
+	}
+
+	return versions, nil
+}
+
+func (f fakeRepository) Dependencies(ctx context.Context, name string, ver string) ([]parser.PackageDescription, error) {
+	return f[name][ver], nil
+}
+
+var repo = fakeRepository{
+	"packet-1": {
+		"1.10": {{Name: "packet-3", Version: "<=2.0"}},
+		"1.9":  nil,
+	},
+	"packet-2": {
+		"1.0": {{Name: "packet-3"}},
+	},
+	"packet-3": {
+		"1.5": nil,
+		"2.0": nil,
+		"2.1": nil,
+	},
+}
+
+func TestSelectVersion_Latest(t *testing.T) {
+	ver, err := SelectVersion(context.Background(), repo, "packet-3", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ver != "2.1" {
+		t.Fatalf("Invalid version: expected='2.1'; got='%s'", ver)
+	}
+}
+
+func TestSelectVersion_LessOrEqual(t *testing.T) {
+	ver, err := SelectVersion(context.Background(), repo, "packet-3", "<=2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ver != "2.0" {
+		t.Fatalf("Invalid version: expected='2.0'; got='%s'", ver)
+	}
+}
+
+func TestSelectVersion_NoSatisfying(t *testing.T) {
+	if _, err := SelectVersion(context.Background(), repo, "packet-3", ">=3.0"); !errors.Is(err, ErrNoSatisfyingVersion) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrNoSatisfyingVersion, err)
+	}
+}
+
+// halfPublishedRepository lists version directories like the server does,
+// versions which are not in metadata were not published completely
+type halfPublishedRepository struct {
+	directories []string
+	metadata    map[string]bool
+}
+
+func (h halfPublishedRepository) Versions(ctx context.Context, name string) ([]string, error) {
+	return repository.PublishedVersions(h.directories, func(ver string) bool {
+		return h.metadata[ver]
+	}), nil
+}
+
+func (h halfPublishedRepository) Dependencies(ctx context.Context, name string, ver string) ([]parser.PackageDescription, error) {
+	return nil, nil
+}
+
+func TestSelectVersion_SkipsHalfPublished(t *testing.T) {
+	half := halfPublishedRepository{
+		directories: []string{"1.0", "1.1", "2.0", "latest"},
+		metadata:    map[string]bool{"1.0": true, "1.1": true},
+	}
+
+	ver, err := SelectVersion(context.Background(), half, "packet-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ver != "1.1" {
+		t.Fatalf("Invalid version: expected='1.1'; got='%s'", ver)
+	}
+}
+
+func TestResolve_Transitive(t *testing.T) {
+	roots := []parser.PackageDescription{{Name: "packet-1", Version: ">=1.10"}}
+
+	graph, err := Resolve(context.Background(), repo, roots, DefaultGroups())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node, ok := graph.Nodes["packet-3"]
+	if !ok || node.Version != "2.0" {
+		t.Fatal("packet-3@2.0 was not selected")
+	}
+}
+
+func TestResolve_Conflict(t *testing.T) {
+	roots := []parser.PackageDescription{
+		{Name: "packet-3", Version: ">=2.1"},
+		{Name: "packet-1", Version: ">=1.10"},
+	}
+
+	if _, err := Resolve(context.Background(), repo, roots, DefaultGroups()); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrVersionConflict, err)
+	}
+}
+
+func TestGraph_Paths(t *testing.T) {
+	roots := []parser.PackageDescription{
+		{Name: "packet-1", Version: ">=1.10"},
+		{Name: "packet-2"},
+	}
+
+	graph, err := Resolve(context.Background(), repo, roots, DefaultGroups())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := graph.Paths("packet-3")
+	if len(paths) != 2 {
+		t.Fatalf("Invalid number of paths: expected=2; got=%d", len(paths))
+	}
+
+	var out bytes.Buffer
+	if err := WritePaths(&out, "packages.json", paths); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "packages.json -> packet-1@1.10 (>=1.10) -> packet-3@2.0 (<=2.0)\n" +
+		"packages.json -> packet-2@1.0 (latest) -> packet-3@2.0 (latest)\n"
+	if out.String() != expected {
+		t.Fatalf("Invalid paths: expected='%s'; got='%s'", expected, out.String())
+	}
+}
+
+func TestGraph_WriteTree(t *testing.T) {
+	roots := []parser.PackageDescription{
+		{Name: "packet-1", Version: ">=1.10"},
+		{Name: "packet-2"},
+	}
+
+	graph, err := Resolve(context.Background(), repo, roots, DefaultGroups())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := graph.WriteTree(&out, "packages.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `packages.json
+├── packet-1@1.10 (>=1.10)
+│   └── packet-3@2.0 (<=2.0)
+└── packet-2@1.0 (latest)
+    └── packet-3@2.0 (latest)
+`
+	if out.String() != expected {
+		t.Fatalf("Invalid tree: expected='%s'; got='%s'", expected, out.String())
+	}
+}
+
+func TestGraph_WriteDot(t *testing.T) {
+	roots := []parser.PackageDescription{{Name: "packet-1", Version: ">=1.10"}}
+
+	graph, err := Resolve(context.Background(), repo, roots, DefaultGroups())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := graph.WriteDot(&out, "packages.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), `"packet-1@1.10" -> "packet-3@2.0" [label="<=2.0"];`) {
+		t.Fatalf("Missing edge in DOT output: '%s'", out.String())
+	}
+}
+
+var groupedRepo = fakeRepository{
+	"app": {
+		"1.0": {
+			{Name: "lib", Version: "1.0"},
+			{Name: "linter", Group: parser.GroupDev},
+			{Name: "plugin", Group: parser.GroupOptional},
+		},
+	},
+	"lib":    {"1.0": nil},
+	"linter": {"1.0": nil},
+	"plugin": {"1.0": nil},
+	"tests":  {"1.0": nil},
+}
+
+func TestResolve_DefaultGroups(t *testing.T) {
+	roots := []parser.PackageDescription{
+		{Name: "app"},
+		{Name: "tests", Group: parser.GroupDev},
+	}
+
+	graph, err := Resolve(context.Background(), groupedRepo, roots, DefaultGroups())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(graph.Nodes) != 2 || graph.Nodes["app"] == nil || graph.Nodes["lib"] == nil {
+		t.Fatalf("Only runtime dependencies should be resolved: got=%v", graph.Nodes)
+	}
+}
+
+func TestResolve_WithGroups(t *testing.T) {
+	roots := []parser.PackageDescription{
+		{Name: "app"},
+		{Name: "tests", Group: parser.GroupDev},
+	}
+
+	groups, err := NewGroups([]string{parser.GroupDev, parser.GroupOptional}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	graph, err := Resolve(context.Background(), groupedRepo, roots, groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// development dependencies of dependencies are never resolved
+	if graph.Nodes["linter"] != nil {
+		t.Fatal("Transitive development dependency was resolved")
+	}
+
+	if graph.Nodes["tests"] == nil || graph.Nodes["plugin"] == nil {
+		t.Fatalf("Requested groups were not resolved: got=%v", graph.Nodes)
+	}
+}
+
+func TestNewGroups_Without(t *testing.T) {
+	groups, err := NewGroups([]string{parser.GroupDev}, []string{parser.GroupRuntime})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if groups[parser.GroupRuntime] || !groups[parser.GroupDev] {
+		t.Fatalf("Invalid groups: %v", groups)
+	}
+}
+
+func TestNewGroups_Unknown(t *testing.T) {
+	if _, err := NewGroups([]string{"test"}, nil); !errors.Is(err, ErrUnknownGroup) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnknownGroup, err)
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	deps := []parser.PackageDescription{
+		{Name: "packet-1", Version: ">=1.10"},
+		{Name: "packet-3", Version: ">=3.0"},
+		{Name: "packet-4"},
+	}
+
+	unresolvable := CheckDependencies(context.Background(), repo, deps)
+	if len(unresolvable) != 2 {
+		t.Fatalf("Invalid number of unresolvable dependencies: expected=2; got=%d", len(unresolvable))
+	}
+
+	if unresolvable[0].Dependency.Name != "packet-3" || unresolvable[1].Dependency.Name != "packet-4" {
+		t.Fatalf("Invalid unresolvable dependencies: %v", unresolvable)
+	}
+}
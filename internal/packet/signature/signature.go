@@ -0,0 +1,168 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+)
+
+var (
+	ErrFailedToReadKey        = errors.New("failed to read key file")
+	ErrInvalidKey             = errors.New("invalid Ed25519 key")
+	ErrInvalidSignatureFormat = errors.New("invalid signature format")
+	ErrInvalidSignature       = errors.New("invalid signature")
+	ErrUnknownSigner          = errors.New("package is signed by an untrusted key")
+)
+
+// Signature is stored on the server next to the archive
+type Signature struct {
+	KeyID     string `json:"key_id"`
+	Signature string `json:"signature"`
+}
+
+// Payload is signed by the publisher.
+// Metadata is the content of the metadata file exactly as it is stored on the server.
+type Payload struct {
+	Name     string
+	Version  string
+	Archive  checksum.Digest
+	Metadata []byte
+}
+
+// bytes returns canonical representation of the payload
+func (p Payload) bytes() []byte {
+	metaSum := sha256.Sum256(p.Metadata)
+	return []byte(fmt.Sprintf("pm-signature-v1\nname=%s\nver=%s\narchive-sha256=%s\narchive-size=%d\nmeta-sha256=%s\n",
+		p.Name, p.Version, p.Archive.Sum, p.Archive.Size, hex.EncodeToString(metaSum[:])))
+}
+
+// KeyID is a short fingerprint of a public key
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+func Sign(key ed25519.PrivateKey, payload Payload) *Signature {
+	return &Signature{
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload.bytes())),
+	}
+}
+
+func Decode(data io.Reader) (*Signature, error) {
+	var sig Signature
+	if err := json.NewDecoder(data).Decode(&sig); err != nil {
+		return nil, ErrInvalidSignatureFormat
+	}
+
+	if sig.KeyID == "" || sig.Signature == "" {
+		return nil, ErrInvalidSignatureFormat
+	}
+
+	return &sig, nil
+}
+
+func (s *Signature) Encode(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// LoadPrivateKey reads PEM encoded PKCS #8 Ed25519 private key
+// (e.g. generated by "openssl genpkey -algorithm ed25519")
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, ErrFailedToReadKey
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, ErrInvalidKey
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, ErrInvalidKey
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	return edKey, nil
+}
+
+// Keyring holds public keys of trusted publishers
+type Keyring struct {
+	keys map[string]ed25519.PublicKey
+}
+
+// LoadKeyring reads a file with PEM encoded Ed25519 public keys
+// (e.g. produced by "openssl pkey -in signing.pem -pubout")
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, ErrFailedToReadKey
+	}
+
+	return ParseKeyring(data)
+}
+
+func ParseKeyring(data []byte) (*Keyring, error) {
+	keyring := &Keyring{
+		keys: make(map[string]ed25519.PublicKey),
+	}
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, ErrInvalidKey
+		}
+
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, ErrInvalidKey
+		}
+		keyring.keys[KeyID(edKey)] = edKey
+	}
+
+	return keyring, nil
+}
+
+func (k *Keyring) Verify(sig *Signature, payload Payload) error {
+	key, ok := k.keys[sig.KeyID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSigner, sig.KeyID)
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return ErrInvalidSignatureFormat
+	}
+
+	if !ed25519.Verify(key, payload.bytes(), raw) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+)
+
+var payload = Payload{
+	Name:     "packet-1",
+	Version:  "1.10",
+	Archive:  checksum.Digest{Sum: "b94f6f125c79e3a5ffaa826f584c10d52ada669e6762051b826b55776d05aed2", Size: 9},
+	Metadata: []byte(`{"packets": []}`),
+}
+
+func generateKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate key:", err)
+	}
+
+	return pub, priv
+}
+
+func encodePublicKey(t *testing.T, key ed25519.PublicKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal("Failed to encode public key:", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestVerify_Valid(t *testing.T) {
+	pub, priv := generateKey(t)
+
+	keyring, err := ParseKeyring(encodePublicKey(t, pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keyring.Verify(Sign(priv, payload), payload); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify_ModifiedMetadata(t *testing.T) {
+	pub, priv := generateKey(t)
+
+	keyring, err := ParseKeyring(encodePublicKey(t, pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modified := payload
+	modified.Metadata = []byte(`{"packets": [{"name": "evil"}]}`)
+	if err := keyring.Verify(Sign(priv, payload), modified); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidSignature, err)
+	}
+}
+
+func TestVerify_UnknownSigner(t *testing.T) {
+	pub, _ := generateKey(t)
+	_, otherPriv := generateKey(t)
+
+	keyring, err := ParseKeyring(encodePublicKey(t, pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keyring.Verify(Sign(otherPriv, payload), payload); !errors.Is(err, ErrUnknownSigner) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrUnknownSigner, err)
+	}
+}
+
+func TestSignature_EncodeDecode(t *testing.T) {
+	_, priv := generateKey(t)
+	sig := Sign(priv, payload)
+
+	var buf bytes.Buffer
+	if err := sig.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *decoded != *sig {
+		t.Fatal("invalid decoding")
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	_, priv := generateKey(t)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal("Failed to encode private key:", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal("Failed to create key file:", err)
+	}
+
+	loaded, err := LoadPrivateKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.Equal(priv) {
+		t.Fatal("invalid key")
+	}
+}
+
+func TestLoadPrivateKey_NotPEM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(path, []byte("key"), 0600); err != nil {
+		t.Fatal("Failed to create key file:", err)
+	}
+
+	if _, err := LoadPrivateKey(path); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("Unexpected error: expected='%v'; got='%v'", ErrInvalidKey, err)
+	}
+}
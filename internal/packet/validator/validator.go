@@ -1,8 +1,10 @@
 package validate
 
 import (
+	"path"
 	"reflect"
 	"regexp"
+	"strings"
 	"sync"
 
 	"github.com/go-playground/validator/v10"
@@ -14,11 +16,21 @@ var once sync.Once
 var (
     regexRemoteVersion = `^((<=)|(>=))?((0|[1-9])\d*)\.((0|[1-9])\d*)$`
     regexPacketVersion = `^((0|[1-9])\d*)\.((0|[1-9])\d*)$`
+    // names are parts of remote and local paths, so they could not contain separators or be "." and ".."
+    regexPacketName = `^[A-Za-z0-9][A-Za-z0-9._+-]*$`
 )
 
+var dependencyGroups = map[string]bool{
+    "runtime":  true,
+    "dev":      true,
+    "build":    true,
+    "optional": true,
+}
+
 var (
     remoteVersionMatcher = regexp.MustCompile(regexRemoteVersion)
     packetVersionMatcher = regexp.MustCompile(regexPacketVersion)
+    packetNameMatcher    = regexp.MustCompile(regexPacketName)
 )
 
 func init() {
@@ -29,6 +41,9 @@ func initValidator() {
     v = validator.New()
     v.RegisterValidation("remote_ver", validateRemoteVersion)
     v.RegisterValidation("pack_ver", validatePacketVersion)
+    v.RegisterValidation("pack_name", validatePacketName)
+    v.RegisterValidation("dep_group", validateDependencyGroup)
+    v.RegisterValidation("package_path", validatePackagePath)
 }
 
 func Validator() *validator.Validate {
@@ -61,3 +76,47 @@ func validatePacketVersion(fl validator.FieldLevel) bool {
     return packetVersionMatcher.MatchString(str)
 }
 
+func validatePacketName(fl validator.FieldLevel) bool {
+    if fl.Field().Kind() != reflect.String {
+        return false
+    }
+
+    str := fl.Field().String()
+    if str == "" {
+        return true
+    }
+
+    return packetNameMatcher.MatchString(str)
+}
+
+func validateDependencyGroup(fl validator.FieldLevel) bool {
+    if fl.Field().Kind() != reflect.String {
+        return false
+    }
+
+    str := fl.Field().String()
+    if str == "" {
+        return true
+    }
+
+    return dependencyGroups[str]
+}
+
+// validatePackagePath accepts slash separated relative paths which do not leave the package
+func validatePackagePath(fl validator.FieldLevel) bool {
+    if fl.Field().Kind() != reflect.String {
+        return false
+    }
+
+    str := fl.Field().String()
+    if str == "" {
+        return true
+    }
+
+    if strings.HasPrefix(str, "/") || strings.Contains(str, "\\") || strings.Contains(str, ":") {
+        return false
+    }
+
+    cleaned := path.Clean(str)
+    return cleaned != ".." && !strings.HasPrefix(cleaned, "../")
+}
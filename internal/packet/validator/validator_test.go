@@ -105,3 +105,71 @@ func TestPacketVersion_InvalidCharacters(t *testing.T) {
         t.Fail()
     }
 }
+
+type groupData struct {
+    S string `validate:"dep_group"`
+}
+
+func TestDependencyGroup_Empty(t *testing.T) {
+    data := groupData{}
+
+    if err := Validator().Struct(data); err != nil {
+        t.Fail()
+    }
+}
+
+func TestDependencyGroup_Known(t *testing.T) {
+    for _, group := range []string{"runtime", "dev", "build", "optional"} {
+        if err := Validator().Struct(groupData{group}); err != nil {
+            t.Fatalf("Group '%s' was rejected", group)
+        }
+    }
+}
+
+func TestDependencyGroup_Unknown(t *testing.T) {
+    data := groupData{"test"}
+
+    if err := Validator().Struct(data); err == nil {
+        t.Fail()
+    }
+}
+
+type packagePathData struct {
+    S string `validate:"package_path"`
+}
+
+func TestPackagePath_Valid(t *testing.T) {
+    for _, s := range []string{"", ".", "config", "share/config/", "a/../b"} {
+        if err := Validator().Struct(packagePathData{s}); err != nil {
+            t.Errorf("Path '%s' should be valid: %v", s, err)
+        }
+    }
+}
+
+func TestPackagePath_Invalid(t *testing.T) {
+    for _, s := range []string{"/etc", "..", "../config", "a/../../b", "c:\\config", "a\\..\\.."} {
+        if err := Validator().Struct(packagePathData{s}); err == nil {
+            t.Errorf("Path '%s' should be invalid", s)
+        }
+    }
+}
+
+type packetNameData struct {
+    S string `validate:"pack_name"`
+}
+
+func TestPacketName_Valid(t *testing.T) {
+    for _, s := range []string{"", "packet-1", "some_pack", "lib.core", "g++"} {
+        if err := Validator().Struct(packetNameData{s}); err != nil {
+            t.Errorf("Name '%s' should be valid: %v", s, err)
+        }
+    }
+}
+
+func TestPacketName_Invalid(t *testing.T) {
+    for _, s := range []string{".", "..", "../x", "a/b", "a\\b", "-flag", " pack", "pack@1.0"} {
+        if err := Validator().Struct(packetNameData{s}); err == nil {
+            t.Errorf("Name '%s' should be invalid", s)
+        }
+    }
+}
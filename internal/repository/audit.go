@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/Elementary1092/pm/internal/adapter/pmssh"
+	"github.com/Elementary1092/pm/internal/directory"
+)
+
+const (
+	AuditActionOverwrite = "overwrite"
+)
+
+// AuditEntry is appended as a JSON line to the audit log of a package on the server
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Name     string    `json:"name"`
+	Version  string    `json:"ver"`
+	User     string    `json:"user"`
+	Host     string    `json:"host"`
+	Previous string    `json:"previous_sha256,omitempty"`
+	Current  string    `json:"sha256,omitempty"`
+	// SignatureRemoved is set if an unsigned archive replaced a signed one
+	SignatureRemoved bool `json:"signature_removed,omitempty"`
+}
+
+// NewAuditEntry fills in time and information about the local user
+func NewAuditEntry(action string, name string, ver string) *AuditEntry {
+	entry := &AuditEntry{
+		Time:    time.Now().UTC(),
+		Action:  action,
+		Name:    name,
+		Version: ver,
+	}
+
+	if u, err := user.Current(); err == nil {
+		entry.User = u.Username
+	}
+
+	if host, err := os.Hostname(); err == nil {
+		entry.Host = host
+	}
+
+	return entry
+}
+
+// Exists checks metadata of a version, because archive name depends on its format
+func (r *Remote) Exists(ctx context.Context, name string, ver string) bool {
+	return pmssh.DoesFileExist(ctx, directory.MakeRemoteMetadataName(name, ver))
+}
+
+func (r *Remote) RecordAudit(ctx context.Context, entry *AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return pmssh.AppendToFile(ctx, directory.MakeRemoteAuditLogName(entry.Name), append(data, '\n'))
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/Elementary1092/pm/internal/adapter/pmssh"
+	"github.com/Elementary1092/pm/internal/directory"
+	"github.com/Elementary1092/pm/internal/packet/archiver"
+	"github.com/Elementary1092/pm/internal/packet/checksum"
+	"github.com/Elementary1092/pm/internal/packet/manifest"
+	"github.com/Elementary1092/pm/internal/packet/metadata"
+	"github.com/Elementary1092/pm/internal/packet/parser"
+	"github.com/Elementary1092/pm/internal/packet/signature"
+	"github.com/Elementary1092/pm/internal/version"
+)
+
+var (
+	ErrNoPublishedManifest = errors.New("version is published without a manifest")
+)
+
+// Remote reads information about published packages from the server.
+// pmssh.Connect should be called before using it.
+type Remote struct{}
+
+func NewRemote() *Remote {
+	return &Remote{}
+}
+
+// Versions lists version directories of a package on the server.
+// Versions without metadata are skipped: metadata is uploaded last, so they are not completely published.
+func (r *Remote) Versions(ctx context.Context, name string) ([]string, error) {
+	entries, err := pmssh.ListDirectory(ctx, directory.MakeRemotePacketDirectory(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return PublishedVersions(entries, func(ver string) bool {
+		return pmssh.DoesFileExist(ctx, directory.MakeRemoteMetadataName(name, ver))
+	}), nil
+}
+
+// PublishedVersions returns valid versions of entries for which hasMetadata reports true
+func PublishedVersions(entries []string, hasMetadata func(ver string) bool) []string {
+	versions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if version.IsValid(entry) && hasMetadata(entry) {
+			versions = append(versions, entry)
+		}
+	}
+
+	return versions
+}
+
+// MetadataFile returns metadata file exactly as it is stored on the server
+func (r *Remote) MetadataFile(ctx context.Context, name string, ver string) ([]byte, error) {
+	return pmssh.ReadFile(ctx, directory.MakeRemoteMetadataName(name, ver))
+}
+
+func (r *Remote) Metadata(ctx context.Context, name string, ver string) (*metadata.Metadata, error) {
+	data, err := r.MetadataFile(ctx, name, ver)
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata.Decode(bytes.NewReader(data))
+}
+
+// Signature returns detached signature of the archive of the given format.
+// An error is returned if the package is unsigned.
+func (r *Remote) Signature(ctx context.Context, name string, ver string, format archiver.Format) (*signature.Signature, error) {
+	data, err := pmssh.ReadFile(ctx, directory.MakeRemoteSignatureName(name, ver, name+format.Extension()))
+	if err != nil {
+		return nil, err
+	}
+
+	return signature.Decode(bytes.NewReader(data))
+}
+
+// Manifest returns manifest of all files of a version verified against the checksum from its metadata
+func (r *Remote) Manifest(ctx context.Context, name string, ver string, meta *metadata.Metadata) (*manifest.Manifest, error) {
+	expected, ok := meta.ManifestDigest()
+	if !ok {
+		return nil, ErrNoPublishedManifest
+	}
+
+	data, err := pmssh.ReadFile(ctx, directory.MakeRemoteManifestName(name, ver))
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := checksum.Compute(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := expected.Verify(actual); err != nil {
+		return nil, err
+	}
+
+	return manifest.Decode(bytes.NewReader(data))
+}
+
+func (r *Remote) Dependencies(ctx context.Context, name string, ver string) ([]parser.PackageDescription, error) {
+	meta, err := r.Metadata(ctx, name, ver)
+	if err != nil {
+		return nil, err
+	}
+
+	return meta.Packets, nil
+}
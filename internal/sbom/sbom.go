@@ -0,0 +1,224 @@
+package sbom
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	toolName    = "pm"
+	noAssertion = "NOASSERTION"
+)
+
+// Package is a resolved package described by a bill of materials
+type Package struct {
+	Name    string
+	Version string
+	// SHA256 of the package archive, empty if unknown
+	SHA256 string
+	// License is an SPDX license expression, empty if it is not declared
+	License string
+	// DependsOn lists names of packages this package depends on
+	DependsOn []string
+}
+
+// Input holds everything needed to produce a bill of materials
+type Input struct {
+	// Name of the document, usually the name of a package description file
+	Name string
+	// Roots are names of packages listed in a package description file
+	Roots    []string
+	Packages []Package
+	Created  time.Time
+}
+
+func (in *Input) sorted() []Package {
+	packages := make([]Package, len(in.Packages))
+	copy(packages, in.Packages)
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+
+	return packages
+}
+
+func (in *Input) versions() map[string]string {
+	versions := make(map[string]string, len(in.Packages))
+	for _, p := range in.Packages {
+		versions[p.Name] = p.Version
+	}
+
+	return versions
+}
+
+var spdxIDForbidden = regexp.MustCompile(`[^a-zA-Z0-9.-]`)
+
+func spdxID(name string) string {
+	return "SPDXRef-Package-" + spdxIDForbidden.ReplaceAllString(name, "-")
+}
+
+// WriteSPDX writes an SPDX 2.3 JSON document
+func WriteSPDX(w io.Writer, in *Input) error {
+	uuid, err := newUUID()
+	if err != nil {
+		return err
+	}
+
+	doc := map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              in.Name,
+		"documentNamespace": fmt.Sprintf("https://spdx.org/spdxdocs/%s-%s", spdxIDForbidden.ReplaceAllString(in.Name, "-"), uuid),
+		"creationInfo": map[string]any{
+			"created":  in.Created.UTC().Format(time.RFC3339),
+			"creators": []string{"Tool: " + toolName},
+		},
+	}
+
+	packages := make([]map[string]any, 0, len(in.Packages))
+	relationships := make([]map[string]any, 0)
+	for _, root := range in.Roots {
+		relationships = append(relationships, map[string]any{
+			"spdxElementId":      "SPDXRef-DOCUMENT",
+			"relationshipType":   "DESCRIBES",
+			"relatedSpdxElement": spdxID(root),
+		})
+	}
+
+	for _, p := range in.sorted() {
+		license := p.License
+		if license == "" {
+			license = noAssertion
+		}
+
+		pack := map[string]any{
+			"SPDXID":           spdxID(p.Name),
+			"name":             p.Name,
+			"versionInfo":      p.Version,
+			"downloadLocation": noAssertion,
+			"filesAnalyzed":    false,
+			"licenseConcluded": noAssertion,
+			"licenseDeclared":  license,
+			"copyrightText":    noAssertion,
+		}
+		if p.SHA256 != "" {
+			pack["checksums"] = []map[string]string{{
+				"algorithm":     "SHA256",
+				"checksumValue": p.SHA256,
+			}}
+		}
+		packages = append(packages, pack)
+
+		for _, dep := range p.DependsOn {
+			relationships = append(relationships, map[string]any{
+				"spdxElementId":      spdxID(p.Name),
+				"relationshipType":   "DEPENDS_ON",
+				"relatedSpdxElement": spdxID(dep),
+			})
+		}
+	}
+	doc["packages"] = packages
+	doc["relationships"] = relationships
+
+	return encode(w, doc)
+}
+
+// WriteCycloneDX writes a CycloneDX 1.5 JSON document
+func WriteCycloneDX(w io.Writer, in *Input) error {
+	uuid, err := newUUID()
+	if err != nil {
+		return err
+	}
+
+	versions := in.versions()
+	ref := func(name string) string {
+		return name + "@" + versions[name]
+	}
+
+	roots := make([]string, 0, len(in.Roots))
+	for _, root := range in.Roots {
+		roots = append(roots, ref(root))
+	}
+
+	components := make([]map[string]any, 0, len(in.Packages))
+	dependencies := make([]map[string]any, 0, len(in.Packages)+1)
+	dependencies = append(dependencies, map[string]any{
+		"ref":       in.Name,
+		"dependsOn": roots,
+	})
+	for _, p := range in.sorted() {
+		component := map[string]any{
+			"type":    "library",
+			"bom-ref": ref(p.Name),
+			"name":    p.Name,
+			"version": p.Version,
+		}
+		if p.SHA256 != "" {
+			component["hashes"] = []map[string]string{{
+				"alg":     "SHA-256",
+				"content": p.SHA256,
+			}}
+		}
+		if p.License != "" {
+			component["licenses"] = []map[string]string{{
+				"expression": p.License,
+			}}
+		}
+		components = append(components, component)
+
+		dependsOn := make([]string, 0, len(p.DependsOn))
+		for _, dep := range p.DependsOn {
+			dependsOn = append(dependsOn, ref(dep))
+		}
+		dependencies = append(dependencies, map[string]any{
+			"ref":       ref(p.Name),
+			"dependsOn": dependsOn,
+		})
+	}
+
+	doc := map[string]any{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + uuid,
+		"version":      1,
+		"metadata": map[string]any{
+			"timestamp": in.Created.UTC().Format(time.RFC3339),
+			"tools": []map[string]string{{
+				"name": toolName,
+			}},
+			"component": map[string]string{
+				"type":    "application",
+				"bom-ref": in.Name,
+				"name":    in.Name,
+			},
+		},
+		"components":   components,
+		"dependencies": dependencies,
+	}
+
+	return encode(w, doc)
+}
+
+func encode(w io.Writer, doc any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(doc)
+}
+
+// newUUID returns random (version 4) UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,94 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var input = &Input{
+	Name:  "packages.json",
+	Roots: []string{"packet-1"},
+	Packages: []Package{
+		{Name: "packet-3", Version: "2.0"},
+		{Name: "packet-1", Version: "1.10", SHA256: "abc", License: "MIT", DependsOn: []string{"packet-3"}},
+	},
+	Created: time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC),
+}
+
+func TestWriteSPDX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSPDX(&buf, input); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		SPDXVersion string `json:"spdxVersion"`
+		Packages    []struct {
+			SPDXID          string `json:"SPDXID"`
+			Name            string `json:"name"`
+			LicenseDeclared string `json:"licenseDeclared"`
+			Checksums       []struct {
+				ChecksumValue string `json:"checksumValue"`
+			} `json:"checksums"`
+		} `json:"packages"`
+		Relationships []struct {
+			Element string `json:"spdxElementId"`
+			Type    string `json:"relationshipType"`
+			Related string `json:"relatedSpdxElement"`
+		} `json:"relationships"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal("Invalid JSON:", err)
+	}
+
+	if doc.SPDXVersion != "SPDX-2.3" || len(doc.Packages) != 2 {
+		t.Fatalf("Invalid document: %s", buf.String())
+	}
+
+	first := doc.Packages[0]
+	if first.SPDXID != "SPDXRef-Package-packet-1" || first.LicenseDeclared != "MIT" || first.Checksums[0].ChecksumValue != "abc" {
+		t.Fatalf("Invalid package: %+v", first)
+	}
+
+	if doc.Packages[1].LicenseDeclared != noAssertion {
+		t.Fatalf("Undeclared license should be NOASSERTION: %+v", doc.Packages[1])
+	}
+
+	if len(doc.Relationships) != 2 || doc.Relationships[1].Type != "DEPENDS_ON" || doc.Relationships[1].Related != "SPDXRef-Package-packet-3" {
+		t.Fatalf("Invalid relationships: %+v", doc.Relationships)
+	}
+}
+
+func TestWriteCycloneDX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCycloneDX(&buf, input); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		BOMFormat  string `json:"bomFormat"`
+		Components []struct {
+			Ref    string `json:"bom-ref"`
+			Hashes []struct {
+				Content string `json:"content"`
+			} `json:"hashes"`
+		} `json:"components"`
+		Dependencies []struct {
+			Ref       string   `json:"ref"`
+			DependsOn []string `json:"dependsOn"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal("Invalid JSON:", err)
+	}
+
+	if doc.BOMFormat != "CycloneDX" || len(doc.Components) != 2 || doc.Components[0].Ref != "packet-1@1.10" {
+		t.Fatalf("Invalid document: %s", buf.String())
+	}
+
+	if len(doc.Dependencies) != 3 || doc.Dependencies[1].DependsOn[0] != "packet-3@2.0" {
+		t.Fatalf("Invalid dependencies: %+v", doc.Dependencies)
+	}
+}
@@ -31,9 +31,20 @@ func Type(v string) versionType {
         return GreaterOrEqual
     }
 
+    if strings.HasPrefix(v, "<") {
+        return Less
+    }
+
+    if strings.HasPrefix(v, ">") {
+        return Greater
+    }
+
     return Exact
 }
 
+// constraintOperators are prefixes of version constraints, longer ones go first
+var constraintOperators = []string{"<=", ">=", "<", ">"}
+
 func Clean(v string) string {
     return strings.TrimLeft(v, "<>=")
 }
@@ -87,3 +98,91 @@ func CompareVersions(v1 string, v2 string) (versionType, error) {
     return Exact, nil
 }
 
+
+// IsValid reports whether v is a plain version without a constraint prefix
+func IsValid(v string) bool {
+    _, _, err := parseVersion(v)
+    return err == nil
+}
+
+// IsValidConstraint reports whether c is a version with an optional <=, >=, < or > prefix
+func IsValidConstraint(c string) bool {
+    for _, op := range constraintOperators {
+        if strings.HasPrefix(c, op) {
+            return IsValid(strings.TrimPrefix(c, op))
+        }
+    }
+
+    return IsValid(c)
+}
+
+// Satisfies reports whether v matches constraint.
+// An empty constraint is satisfied by any valid version.
+func Satisfies(v string, constraint string) (bool, error) {
+    if constraint == "" {
+        return IsValid(v), nil
+    }
+
+    cmp, err := CompareVersions(v, Clean(constraint))
+    if err != nil {
+        return false, err
+    }
+
+    switch Type(constraint) {
+    case LessOrEqual:
+        return cmp == Less || cmp == Exact, nil
+    case GreaterOrEqual:
+        return cmp == Greater || cmp == Exact, nil
+    case Less:
+        return cmp == Less, nil
+    case Greater:
+        return cmp == Greater, nil
+    default:
+        return cmp == Exact, nil
+    }
+}
+
+// Latest returns the highest valid version among versions satisfying constraint.
+// Empty string is returned if there is no such version.
+func Latest(versions []string, constraint string) string {
+    latest := ""
+    for _, v := range versions {
+        ok, err := Satisfies(v, constraint)
+        if err != nil || !ok {
+            continue
+        }
+
+        if latest == "" {
+            latest = v
+            continue
+        }
+
+        if cmp, _ := CompareVersions(v, latest); cmp == Greater {
+            latest = v
+        }
+    }
+
+    return latest
+}
+
+// Preceding returns the highest valid version among versions which is lower than v.
+// Empty string is returned if there is no such version.
+func Preceding(versions []string, v string) string {
+    preceding := ""
+    for _, candidate := range versions {
+        if cmp, err := CompareVersions(candidate, v); err != nil || cmp != Less {
+            continue
+        }
+
+        if preceding == "" {
+            preceding = candidate
+            continue
+        }
+
+        if cmp, _ := CompareVersions(candidate, preceding); cmp == Greater {
+            preceding = candidate
+        }
+    }
+
+    return preceding
+}
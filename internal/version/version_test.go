@@ -0,0 +1,91 @@
+package version
+
+import "testing"
+
+func TestSatisfies_EmptyConstraint(t *testing.T) {
+    if ok, err := Satisfies("1.0", ""); err != nil || !ok {
+        t.Fail()
+    }
+}
+
+func TestSatisfies_Exact(t *testing.T) {
+    if ok, err := Satisfies("1.0", "1.1"); err != nil || ok {
+        t.Fail()
+    }
+}
+
+func TestSatisfies_LessOrEqual(t *testing.T) {
+    if ok, err := Satisfies("1.9", "<=1.10"); err != nil || !ok {
+        t.Fail()
+    }
+}
+
+func TestSatisfies_GreaterOrEqual(t *testing.T) {
+    if ok, err := Satisfies("1.9", ">=1.10"); err != nil || ok {
+        t.Fail()
+    }
+}
+
+func TestSatisfies_Less(t *testing.T) {
+    if ok, err := Satisfies("1.3", "<1.4"); err != nil || !ok {
+        t.Fail()
+    }
+
+    if ok, err := Satisfies("1.4", "<1.4"); err != nil || ok {
+        t.Fail()
+    }
+}
+
+func TestSatisfies_Greater(t *testing.T) {
+    if ok, err := Satisfies("1.1", ">1.0"); err != nil || !ok {
+        t.Fail()
+    }
+
+    if ok, err := Satisfies("1.0", ">1.0"); err != nil || ok {
+        t.Fail()
+    }
+}
+
+func TestIsValidConstraint(t *testing.T) {
+    for _, c := range []string{"1.0", "<=1.0", ">=1.0", "<1.0", ">1.0"} {
+        if !IsValidConstraint(c) {
+            t.Fatalf("Constraint '%s' should be valid", c)
+        }
+    }
+
+    for _, c := range []string{"", "~1.0", "!=1.0", "=1.0", "<<1.0", "<1.0.1"} {
+        if IsValidConstraint(c) {
+            t.Fatalf("Constraint '%s' should be invalid", c)
+        }
+    }
+}
+
+func TestSatisfies_InvalidVersion(t *testing.T) {
+    if _, err := Satisfies("latest", ">=1.10"); err == nil {
+        t.Fail()
+    }
+}
+
+func TestLatest_WithConstraint(t *testing.T) {
+    if v := Latest([]string{"1.2", "1.10", "2.0", "latest"}, "<=1.10"); v != "1.10" {
+        t.Fatalf("Invalid version: expected='1.10'; got='%s'", v)
+    }
+}
+
+func TestLatest_NoSatisfying(t *testing.T) {
+    if v := Latest([]string{"1.2", "1.10"}, ">=2.0"); v != "" {
+        t.Fatalf("Invalid version: expected=''; got='%s'", v)
+    }
+}
+
+func TestPreceding(t *testing.T) {
+    if v := Preceding([]string{"1.2", "1.10", "2.0", "latest"}, "2.0"); v != "1.10" {
+        t.Fatalf("Invalid version: expected='1.10'; got='%s'", v)
+    }
+}
+
+func TestPreceding_First(t *testing.T) {
+    if v := Preceding([]string{"1.2", "1.10"}, "1.2"); v != "" {
+        t.Fatalf("Invalid version: expected=''; got='%s'", v)
+    }
+}